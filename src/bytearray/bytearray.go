@@ -2,13 +2,36 @@ package bytearray
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
 )
 
 type ByteArray []byte
 
-func charToValue(b byte) (byte, error) {
+// An error in bytes given in hex: the character at Pos (counting from 0)
+// of Input, Char, is not a hex digit, or (with Pos -1) Input has an odd
+// number of digits.
+type ParseError struct {
+	Input string
+	Pos   int
+	Char  byte
+}
+
+func (e *ParseError) Error() string {
+	if e.Pos < 0 {
+		return fmt.Sprintf("%q has an odd number (%d) of hex digits; a byte sequence needs two for each byte (pad it with a leading 0)", e.Input, len(e.Input))
+	}
+	return fmt.Sprintf("%q is not a hex digit, at position %d of %q", e.Char, e.Pos+1, e.Input)
+}
+
+// Returns the value of the hex digit value[i].
+func charToValue(value string, i int) (byte, error) {
+	b := value[i]
 	if b >= '0' && b <= '9' {
 		return b - '0', nil
 	} else if b >= 'a' && b <= 'f' {
@@ -16,24 +39,50 @@ func charToValue(b byte) (byte, error) {
 	} else if b >= 'A' && b <= 'F' {
 		return b - 'A' + 10, nil
 	}
-	return 0, fmt.Errorf("%q is not a valid hex character", b)
+	return 0, &ParseError{value, i, b}
 }
 
+// Returns the bytes given in hex by value. If padOdd is set, an odd
+// number of digits is accepted as though it began with a 0 (e.g., "abc"
+// as "0abc"); otherwise it is an error.
+func ParseHex(value string, padOdd bool) (ByteArray, error) {
+	digits := value
+	if len(value)%2 != 0 && padOdd {
+		digits = "0" + value
+	}
+	var n ByteArray
+	if err := n.setHex(digits); err != nil {
+		// the error is given for value as written
+		e := err.(*ParseError)
+		if e.Pos >= 0 {
+			e.Pos -= len(digits) - len(value)
+		}
+		e.Input = value
+		return nil, e
+	}
+	return n, nil
+}
+
+// Sets n to the bytes given in hex by value. It never reads a file, so it
+// is safe for data as well as flags; see FlagBytes for "@PATH".
 func (n *ByteArray) Set(value string) error {
+	return n.setHex(value)
+}
+
+// Sets n to the bytes given in hex by value.
+func (n *ByteArray) setHex(value string) error {
 	l := len(value)
 	if l%2 != 0 {
-		return errors.New("must specify an even number of (hex) characters to specify a byte sequence")
+		return &ParseError{value, -1, 0}
 	}
 	*n = make([]byte, 0, l/2)
 	for i := 0; i < l; i += 2 {
 		var err error
 		var v1, v2 byte
-		b1 := value[i]
-		b2 := value[i+1]
-		if v1, err = charToValue(b1); err != nil {
+		if v1, err = charToValue(value, i); err != nil {
 			return err
 		}
-		if v2, err = charToValue(b2); err != nil {
+		if v2, err = charToValue(value, i+1); err != nil {
 			return err
 		}
 
@@ -50,3 +99,300 @@ func (n *ByteArray) String() string {
 	}
 	return buf.String()
 }
+
+// Returns n in hex, as String does (encoding.TextMarshaler).
+func (n ByteArray) MarshalText() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+// Sets n to the bytes given in hex by text (encoding.TextUnmarshaler).
+func (n *ByteArray) UnmarshalText(text []byte) error {
+	return n.setHex(string(text))
+}
+
+// Returns n as a JSON string holding its hex (json.Marshaler).
+func (n ByteArray) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.String())
+}
+
+// Sets n to the bytes given in hex by a JSON string (json.Unmarshaler).
+func (n *ByteArray) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return n.setHex(s)
+}
+
+// Formats n for the fmt package (fmt.Formatter): %s and %v give its hex as
+// String does, %x and %X its hex in lower and upper case (with a 0x prefix
+// given the # flag), and %q its bytes as a quoted Go string.
+func (n ByteArray) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 's', 'v':
+		io.WriteString(f, n.String())
+	case 'x', 'X':
+		if f.Flag('#') {
+			io.WriteString(f, "0"+string(verb))
+		}
+		s := n.String()
+		if verb == 'x' {
+			s = strings.ToLower(s)
+		}
+		io.WriteString(f, s)
+	case 'q':
+		io.WriteString(f, strconv.Quote(string(n)))
+	default:
+		fmt.Fprintf(f, "%%!%c(bytearray.ByteArray=%s)", verb, n.String())
+	}
+}
+
+// A way of writing a ByteArray, for Styled.
+type Style int
+
+const (
+	StyleHex     Style = iota // upper-case hex, as String gives: DEAD00
+	StyleLower                // lower-case hex: dead00
+	StyleGrouped              // bytes separated by spaces: DE AD 00
+	StyleEscaped              // every byte escaped: \xde\xad\x00
+	StyleGo                   // a Go literal: []byte{0xde, 0xad, 0x00}
+	StyleC                    // a C array initializer: {0xde, 0xad, 0x00}
+)
+
+// the names of the styles, as ParseStyle reads them
+var styleNames = []string{"hex", "lower", "grouped", "escaped", "go", "c"}
+
+// Returns the style called name: hex, lower, grouped, escaped, go, or c.
+func ParseStyle(name string) (Style, error) {
+	for i, s := range styleNames {
+		if name == s {
+			return Style(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a style; use one of %s", name, strings.Join(styleNames, ", "))
+}
+
+func (s Style) String() string {
+	if s < 0 || int(s) >= len(styleNames) {
+		return fmt.Sprintf("Style(%d)", int(s))
+	}
+	return styleNames[s]
+}
+
+// Returns n written in style.
+func (n ByteArray) Styled(style Style) string {
+	var buf bytes.Buffer
+	switch style {
+	case StyleLower:
+		for _, b := range n {
+			fmt.Fprintf(&buf, "%02x", b)
+		}
+	case StyleGrouped:
+		for i, b := range n {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			fmt.Fprintf(&buf, "%02X", b)
+		}
+	case StyleEscaped:
+		for _, b := range n {
+			fmt.Fprintf(&buf, "\\x%02x", b)
+		}
+	case StyleGo, StyleC:
+		if style == StyleGo {
+			buf.WriteString("[]byte")
+		}
+		buf.WriteByte('{')
+		for i, b := range n {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "0x%02x", b)
+		}
+		buf.WriteByte('}')
+	default:
+		return n.String()
+	}
+	return buf.String()
+}
+
+// Bytes given in hex in which either digit of a byte may be "?", matching
+// any value, e.g., "DE??BEEF". Mask has the bits that must match set, and
+// is nil if every bit must.
+type MaskedByteArray struct {
+	Bytes ByteArray
+	Mask  []byte
+}
+
+func (n *MaskedByteArray) Set(value string) error {
+	l := len(value)
+	if l%2 != 0 {
+		return &ParseError{value, -1, 0}
+	}
+	n.Bytes = make([]byte, 0, l/2)
+	n.Mask = make([]byte, 0, l/2)
+	masked := false
+	for i := 0; i < l; i++ {
+		var v, m byte
+		if value[i] != '?' {
+			var err error
+			if v, err = charToValue(value, i); err != nil {
+				return err
+			}
+			m = 0xf
+		} else {
+			masked = true
+		}
+
+		if i%2 == 0 {
+			n.Bytes = append(n.Bytes, v<<4)
+			n.Mask = append(n.Mask, m<<4)
+		} else {
+			n.Bytes[i/2] |= v
+			n.Mask[i/2] |= m
+		}
+	}
+	if !masked {
+		n.Mask = nil
+	}
+
+	return nil
+}
+
+func (n *MaskedByteArray) String() string {
+	var buf bytes.Buffer
+	for i, b := range n.Bytes {
+		for _, shift := range []uint{4, 0} {
+			if n.Mask != nil && (n.Mask[i]>>shift)&0xf == 0 {
+				buf.WriteByte('?')
+			} else {
+				buf.WriteString(fmt.Sprintf("%X", (b>>shift)&0xf))
+			}
+		}
+	}
+	return buf.String()
+}
+
+// Returns the bytes written in value as a C-style string, in which
+// printable characters stand for themselves and \xNN, \n, \r, \t, \0,
+// \\, \", and \' give others, e.g., "GET \x00\x01". value may be enclosed
+// in double quotes, which are removed.
+func ParseEscaped(value string) (ByteArray, error) {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	n := make(ByteArray, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			n = append(n, value[i])
+			continue
+		}
+		if i++; i == len(value) {
+			return nil, errors.New("the escape \\ at the end has no character following it")
+		}
+		switch c := value[i]; c {
+		case 'n':
+			n = append(n, '\n')
+		case 'r':
+			n = append(n, '\r')
+		case 't':
+			n = append(n, '\t')
+		case '0':
+			n = append(n, 0)
+		case '\\', '"', '\'':
+			n = append(n, c)
+		case 'x':
+			if i+2 >= len(value) {
+				return nil, fmt.Errorf("the escape \\x at %d must be followed by two hex characters", i-1)
+			}
+			v1, err := charToValue(value, i+1)
+			if err != nil {
+				return nil, err
+			}
+			v2, err := charToValue(value, i+2)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, v1*16+v2)
+			i += 2
+		default:
+			return nil, fmt.Errorf("\\%c at %d is not a known escape", c, i-1)
+		}
+	}
+	return n, nil
+}
+
+// Sets n to the bytes of value, a C-style string as for ParseEscaped.
+func (n *ByteArray) SetEscaped(value string) error {
+	b, err := ParseEscaped(value)
+	if err != nil {
+		return err
+	}
+	*n = b
+	return nil
+}
+
+// Returns the contents of the file named by value, a path following "@",
+// which may not be empty.
+func readFileValue(value string) (ByteArray, error) {
+	data, err := ioutil.ReadFile(value[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", value[1:])
+	}
+	return data, nil
+}
+
+// A flag.Value that sets Target to the bytes given in hex or, if the value
+// is "@" followed by a path, to the contents of that file. It is only for
+// command-line flags: a value read from data (e.g., a plan) must not be
+// able to have a file read, so data is parsed by ByteArray's Set.
+type FlagBytes struct {
+	Target *ByteArray
+}
+
+func (f FlagBytes) String() string {
+	if f.Target == nil {
+		return ""
+	}
+	return f.Target.String()
+}
+
+func (f FlagBytes) Set(value string) error {
+	if len(value) > 0 && value[0] == '@' {
+		b, err := readFileValue(value)
+		if err != nil {
+			return err
+		}
+		*f.Target = b
+		return nil
+	}
+	return f.Target.Set(value)
+}
+
+// A flag.Value as FlagBytes is, for a MaskedByteArray; the contents of a
+// file given as "@PATH" must match in full.
+type FlagMaskedBytes struct {
+	Target *MaskedByteArray
+}
+
+func (f FlagMaskedBytes) String() string {
+	if f.Target == nil {
+		return ""
+	}
+	return f.Target.String()
+}
+
+func (f FlagMaskedBytes) Set(value string) error {
+	if len(value) > 0 && value[0] == '@' {
+		b, err := readFileValue(value)
+		if err != nil {
+			return err
+		}
+		f.Target.Bytes, f.Target.Mask = b, nil
+		return nil
+	}
+	return f.Target.Set(value)
+}
@@ -0,0 +1,173 @@
+/*
+This file includes tests for the bytearray package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package bytearray
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEscaped(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{`GET \x00\x01`, "GET \x00\x01"},
+		{`"a\tb\n"`, "a\tb\n"},
+		{`\0\\\"\'\r`, "\x00\\\"'\r"},
+		{`\xfF`, "\xff"},
+		{`"`, `"`},
+	}
+	for _, c := range cases {
+		got, err := ParseEscaped(c.in)
+		if err != nil || string(got) != c.want {
+			t.Error(fmt.Sprintf("ParseEscaped(%q) = %q, %v; expected %q", c.in, string(got), err, c.want))
+		}
+	}
+
+	for _, in := range []string{`a\`, `\x0`, `\xg0`, `\q`} {
+		if _, err := ParseEscaped(in); err == nil {
+			t.Error(fmt.Sprintf("expected an error for %q", in))
+		}
+	}
+}
+
+func TestSetFromFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "needle")
+	if err := ioutil.WriteFile(name, []byte("\x00ab\xff"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var b ByteArray
+	err := (FlagBytes{&b}).Set("@" + name)
+	if err != nil || string(b) != "\x00ab\xff" {
+		t.Error(fmt.Sprintf("got %q, %v", string(b), err))
+	}
+	var m MaskedByteArray
+	if err = (FlagMaskedBytes{&m}).Set("@" + name); err != nil || string(m.Bytes) != "\x00ab\xff" || m.Mask != nil {
+		t.Error(fmt.Sprintf("got %q (mask %v), %v", string(m.Bytes), m.Mask, err))
+	}
+
+	if err = (FlagBytes{&b}).Set("@" + filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	empty := filepath.Join(dir, "empty")
+	ioutil.WriteFile(empty, nil, 0644)
+	if err = (FlagBytes{&b}).Set("@" + empty); err == nil {
+		t.Error("expected an error for an empty file")
+	}
+
+	// data is never read as a path
+	if err = b.Set("@" + name); err == nil {
+		t.Error("expected ByteArray.Set to refuse @PATH")
+	}
+	if err = m.Set("@" + name); err == nil {
+		t.Error("expected MaskedByteArray.Set to refuse @PATH")
+	}
+}
+
+func TestMarshaling(t *testing.T) {
+	type config struct {
+		Needle ByteArray `json:"needle"`
+	}
+	data, err := json.Marshal(config{ByteArray{0xde, 0xad}})
+	if err != nil || string(data) != `{"needle":"DEAD"}` {
+		t.Error(fmt.Sprintf("json.Marshal gave %s, %v", data, err))
+	}
+	var c config
+	if err = json.Unmarshal([]byte(`{"needle":"beef00"}`), &c); err != nil || string(c.Needle) != "\xbe\xef\x00" {
+		t.Error(fmt.Sprintf("json.Unmarshal gave %q, %v", string(c.Needle), err))
+	}
+	if err = json.Unmarshal([]byte(`{"needle":"@/etc/hostname"}`), &c); err == nil {
+		t.Error("expected an error for @PATH in JSON")
+	}
+
+	var b ByteArray
+	if err = b.UnmarshalText([]byte("0a0B")); err != nil || string(b) != "\x0a\x0b" {
+		t.Error(fmt.Sprintf("UnmarshalText gave %q, %v", string(b), err))
+	}
+	if text, _ := b.MarshalText(); string(text) != "0A0B" {
+		t.Error(fmt.Sprintf("MarshalText gave %s", text))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	b := ByteArray("A\x00\xff")
+	got := fmt.Sprintf("%s %v %x %X %#x %q %d", b, b, b, b, b, b, b)
+	want := `4100FF 4100FF 4100ff 4100FF 0x4100ff "A\x00\xff" %!d(bytearray.ByteArray=4100FF)`
+	if got != want {
+		t.Error(fmt.Sprintf("got %s; expected %s", got, want))
+	}
+}
+
+func TestStyled(t *testing.T) {
+	b := ByteArray{0xde, 0xad, 0x00}
+	want := map[string]string{
+		"hex":     "DEAD00",
+		"lower":   "dead00",
+		"grouped": "DE AD 00",
+		"escaped": `\xde\xad\x00`,
+		"go":      "[]byte{0xde, 0xad, 0x00}",
+		"c":       "{0xde, 0xad, 0x00}",
+	}
+	for name, w := range want {
+		style, err := ParseStyle(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := b.Styled(style); got != w || style.String() != name {
+			t.Error(fmt.Sprintf("%s gave %s; expected %s", name, got, w))
+		}
+	}
+	if _, err := ParseStyle("octal"); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+	if got := ByteArray(nil).Styled(StyleGo); got != "[]byte{}" {
+		t.Error(fmt.Sprintf("an empty array gave %s", got))
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	if b, err := ParseHex("abc", true); err != nil || string(b) != "\x0a\xbc" {
+		t.Error(fmt.Sprintf("got %q, %v", string(b), err))
+	}
+	if b, err := ParseHex("0abc", false); err != nil || string(b) != "\x0a\xbc" {
+		t.Error(fmt.Sprintf("got %q, %v", string(b), err))
+	}
+
+	_, err := ParseHex("abc", false)
+	if e, ok := err.(*ParseError); !ok || e.Pos != -1 || e.Input != "abc" {
+		t.Error(fmt.Sprintf("expected an odd-length error; got %v", err))
+	}
+	cases := []struct {
+		in     string
+		padOdd bool
+		pos    int
+	}{
+		{"12g4", false, 2},
+		{"1g4", true, 1},
+		{"x", true, 0},
+	}
+	for _, c := range cases {
+		_, err = ParseHex(c.in, c.padOdd)
+		if e, ok := err.(*ParseError); !ok || e.Pos != c.pos || e.Char != c.in[c.pos] || e.Input != c.in {
+			t.Error(fmt.Sprintf("ParseHex(%q, %v) gave %v; expected an error at %d", c.in, c.padOdd, err, c.pos))
+		}
+	}
+
+	var m MaskedByteArray
+	err = m.Set("DE?Z")
+	if e, ok := err.(*ParseError); !ok || e.Pos != 3 || e.Char != 'Z' {
+		t.Error(fmt.Sprintf("expected an error at 3; got %v", err))
+	}
+}
@@ -0,0 +1,92 @@
+/*
+This package recognizes compressed data by its leading bytes and returns a
+reader of the decompressed form, so that tools such as sift can search
+compressed files as though they were not. gzip, bzip2, and zstd are
+supported; zstd is decoded by a copy of the Go project's decoder kept in
+internal/zstd, as the standard library does not export one.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"decompress/internal/zstd"
+	"io"
+)
+
+// A compression format.
+type Format int
+
+const (
+	None Format = iota
+	Gzip
+	Bzip2
+	Zstd
+)
+
+var magic = []struct {
+	format Format
+	prefix []byte
+}{
+	{Gzip, []byte{0x1f, 0x8b}},
+	{Bzip2, []byte("BZh")},
+	{Zstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// The most leading bytes Detect looks at.
+const maxMagic = 4
+
+func (f Format) String() string {
+	switch f {
+	case Gzip:
+		return "gzip"
+	case Bzip2:
+		return "bzip2"
+	case Zstd:
+		return "zstd"
+	}
+	return "none"
+}
+
+// Returns the format of data that begins with header, or None if it is not
+// recognized.
+func Detect(header []byte) Format {
+	for _, m := range magic {
+		if bytes.HasPrefix(header, m.prefix) {
+			return m.format
+		}
+	}
+	return None
+}
+
+// Returns a reader of the decompressed form of r, and the format it was
+// found to be in. If r's format is not recognized (None), the reader
+// returned reads r as is.
+func NewReader(r io.Reader) (io.Reader, Format, error) {
+	in := bufio.NewReader(r)
+	header, err := in.Peek(maxMagic)
+	if err != nil && err != io.EOF {
+		return nil, None, err
+	}
+
+	format := Detect(header)
+	switch format {
+	case Gzip:
+		z, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, format, err
+		}
+		return z, format, nil
+	case Bzip2:
+		return bzip2.NewReader(in), format, nil
+	case Zstd:
+		return zstd.NewReader(in), format, nil
+	}
+	return in, None, nil
+}
@@ -0,0 +1,91 @@
+/*
+This file includes tests for the decompress package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const text = "hello, needle world\n"
+
+// text compressed with bzip2
+const bzip2Hex = "425a6839314159265359649277b4000005518000104004064590802000229e9a0c8201a69a0995900258d55b1acf0bb9229c284832493bda00"
+
+// text compressed with zstd, and 50 copies of text compressed with zstd
+const zstdHex = "28b52ffd0458a1000068656c6c6f2c206e6565646c6520776f726c640aebffe897"
+const zstdRepeatedHex = "28b52ffd0468dd0000a068656c6c6f2c206e6565646c6520776f726c640a0100a25f3599ce225b79"
+
+func expectText(t *testing.T, data []byte, format Format, notation string) {
+	r, got, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Error(fmt.Sprintf("expected no error, got %v (note: %s)", err, notation))
+		return
+	}
+	if got != format {
+		t.Error(fmt.Sprintf("expected format %v, got %v (note: %s)", format, got, notation))
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil || string(out) != text {
+		t.Error(fmt.Sprintf("expected %q, got %q, %v (note: %s)", text, out, err, notation))
+	}
+}
+
+func TestGzip(t *testing.T) {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte(text))
+	z.Close()
+	expectText(t, buf.Bytes(), Gzip, "TestGzip")
+}
+
+func TestBzip2(t *testing.T) {
+	data, _ := hex.DecodeString(bzip2Hex)
+	expectText(t, data, Bzip2, "TestBzip2")
+}
+
+func TestPlain(t *testing.T) {
+	expectText(t, []byte(text), None, "TestPlain")
+
+	r, format, err := NewReader(bytes.NewReader(nil))
+	if err != nil || format != None {
+		t.Error(fmt.Sprintf("expected None and no error for empty data, got %v, %v", format, err))
+	} else if out, _ := ioutil.ReadAll(r); len(out) != 0 {
+		t.Error(fmt.Sprintf("expected no data, got %q", out))
+	}
+}
+
+func TestZstd(t *testing.T) {
+	// a single raw block with a content checksum
+	data, _ := hex.DecodeString(zstdHex)
+	expectText(t, data, Zstd, "TestZstd raw")
+
+	// 50 copies of text, compressed
+	data, _ = hex.DecodeString(zstdRepeatedHex)
+	r, format, err := NewReader(bytes.NewReader(data))
+	if err != nil || format != Zstd {
+		t.Fatal(fmt.Sprintf("expected zstd and no error, got %v, %v", format, err))
+	}
+	if out, err := ioutil.ReadAll(r); err != nil || string(out) != strings.Repeat(text, 50) {
+		t.Error(fmt.Sprintf("expected 50 copies of %q, got %q, %v", text, out, err))
+	}
+
+	r, _, err = NewReader(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0}))
+	if err == nil {
+		_, err = ioutil.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("expected an error for a truncated zstd frame")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+This file implements the copying of one file's contents into another as
+cheaply as the system allows: by sharing the data (a reflink) where the
+file system supports it, and otherwise by copying only the regions that
+hold data, so that the holes of a sparse file stay holes.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package fileutil
+
+import (
+	"io"
+	"os"
+)
+
+// the most copied between reports of progress
+const copy_piece_size = 64 * 1024 * 1024
+
+// Copies the whole of src into dst, which should be empty, leaving dst's
+// offset unspecified. Reads src from its beginning regardless of its
+// offset.
+func CopyFile(dst, src *os.File) error {
+	return CopyFileProgress(dst, src, nil)
+}
+
+// Like CopyFile, but calls progress, if not nil, as the copy proceeds with
+// how far into src it has got and src's size (-1 if it is not a regular
+// file). A copy made by sharing the data reports nothing.
+func CopyFileProgress(dst, src *os.File, progress func(done, total int64)) error {
+	if cloneFile(dst, src) == nil {
+		return nil
+	}
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		if _, err = src.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err = io.Copy(&progressWriter{dst, 0, -1, progress}, src)
+		return err
+	}
+	return copySparse(dst, src, info.Size(), progress)
+}
+
+// Copies the size bytes of src into dst a region of data at a time, as
+// found by dataRegion, leaving the holes between them unwritten. dst is
+// then extended to size, should it end in a hole. progress is as for
+// CopyFileProgress, and is called after each piece of a region.
+func copySparse(dst, src *os.File, size int64, progress func(done, total int64)) error {
+	report := func(done int64) {
+		if progress != nil {
+			progress(done, size)
+		}
+	}
+	for offset := int64(0); offset < size; {
+		start, end, err := dataRegion(src, offset, size)
+		if err != nil {
+			return err
+		}
+		if start >= size {
+			break
+		}
+		if _, err = src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err = dst.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		// copied file to file, so that the system can copy within the
+		// kernel (e.g., copy_file_range on Linux)
+		for start < end {
+			n := end - start
+			if n > copy_piece_size {
+				n = copy_piece_size
+			}
+			if _, err = io.CopyN(dst, src, n); err != nil {
+				return err
+			}
+			start += n
+			report(start)
+		}
+		offset = end
+	}
+	report(size)
+	return dst.Truncate(size)
+}
+
+//// TYPE progressWriter ////
+
+// A writer that reports how far a copy has got as it writes to w.
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	n, err := p.w.Write(data)
+	p.report(p.done + int64(n))
+	return n, err
+}
+
+// Notes that the copy has got to done and passes that on.
+func (p *progressWriter) report(done int64) {
+	p.done = done
+	if p.progress != nil {
+		p.progress(p.done, p.total)
+	}
+}
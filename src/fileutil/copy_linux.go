@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+/*
+This file implements reflinks and the finding of holes on Linux.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	ioctl_ficlone = 0x40049409 // FICLONE, from linux/fs.h
+	seek_data     = 3          // SEEK_DATA
+	seek_hole     = 4          // SEEK_HOLE
+)
+
+// Makes dst share src's data, which only some file systems (e.g., Btrfs
+// and XFS) support, and then only within one file system.
+func cloneFile(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ioctl_ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Returns the region of data in f, of size bytes, that begins at or after
+// offset, ending at the next hole. start is size if there is none.
+func dataRegion(f *os.File, offset, size int64) (start, end int64, err error) {
+	if start, err = syscall.Seek(int(f.Fd()), offset, seek_data); err == syscall.ENXIO {
+		return size, size, nil
+	} else if err != nil {
+		// the file system cannot tell; take the rest as data
+		return offset, size, nil
+	}
+	if end, err = syscall.Seek(int(f.Fd()), start, seek_hole); err != nil {
+		return start, size, nil
+	}
+	return start, end, nil
+}
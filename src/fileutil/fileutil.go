@@ -0,0 +1,162 @@
+/*
+This package implements an atomic file rewrite: the new contents are
+written to a temporary file beside the original, which then replaces the
+original by a rename. It is used by the swap tool and may be used by
+library consumers.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package fileutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// The most temporary files with the same template MakeTempFile will try.
+const maxTempTries = 100
+
+// Rewrites the file at path. fn is called with r reading the file's current
+// contents and w writing a temporary file in the same directory. If fn
+// succeeds, the temporary file is synced, given the original's permissions,
+// and renamed over the original. If fn or any later step fails, the
+// temporary file is removed and the original is left untouched.
+//
+// w is the temporary *os.File, so fn may type-assert it to io.ReaderAt or
+// io.WriterAt when it needs random access to what it has written.
+func AtomicRewrite(path string, fn func(w io.Writer, r io.Reader) error) error {
+	_, err := rewrite(path, false, fn)
+	return err
+}
+
+// Like AtomicRewrite, but the original file is kept under a new name
+// (path.backupN, numbered as by MakeNumberedFile) rather than being
+// replaced. Returns the name of the backup.
+func AtomicRewriteBackup(path string, fn func(w io.Writer, r io.Reader) error) (backupName string, err error) {
+	return rewrite(path, true, fn)
+}
+
+func rewrite(path string, backup bool, fn func(w io.Writer, r io.Reader) error) (backupName string, err error) {
+	var in *os.File
+	if in, err = os.Open(path); err != nil {
+		return
+	}
+	defer in.Close()
+
+	var info os.FileInfo
+	if info, err = in.Stat(); err != nil {
+		return
+	}
+
+	var tempName string
+	tempName, err = writeTemp(path, keptMode(info), func(temp *os.File) error {
+		return fn(temp, in)
+	})
+	if err != nil {
+		return
+	}
+
+	if backup {
+		var backupFile *os.File
+		if backupName, backupFile, err = MakeNumberedFile(path, "backup"); err != nil {
+			os.Remove(tempName)
+			return
+		}
+		backupFile.Close()
+	}
+
+	// some systems (e.g., Windows) cannot replace a file that is open
+	in.Close()
+	if err = replaceFile(path, tempName, backupName); err != nil {
+		os.Remove(tempName)
+		if backupName != "" {
+			os.Remove(backupName)
+			backupName = ""
+		}
+	}
+	return
+}
+
+// Creates the file at path, or replaces it if it exists, with what fn writes
+// to w. w is a temporary file in the same directory that is synced and then
+// renamed to path once fn succeeds, so path never holds partial contents. A
+// new file is given the permissions perm; a replaced one keeps its own. If
+// fn or any later step fails, the temporary file is removed and path is
+// left untouched.
+func AtomicCreate(path string, perm os.FileMode, fn func(w io.Writer) error) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = keptMode(info)
+	}
+
+	tempName, err := writeTemp(path, perm, func(temp *os.File) error {
+		return fn(temp)
+	})
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tempName, path); err != nil {
+		os.Remove(tempName)
+	}
+	return err
+}
+
+// Returns the mode bits of the file described by info that a file replacing
+// it keeps: its permissions, and its setuid, setgid, and sticky bits.
+func keptMode(info os.FileInfo) os.FileMode {
+	return info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// Creates a temporary file beside path, has fn write it, and then syncs it,
+// gives it the permissions perm, and closes it. Returns the temporary
+// file's name. If any step fails, the temporary file is removed.
+func writeTemp(path string, perm os.FileMode, fn func(temp *os.File) error) (tempName string, err error) {
+	var temp *os.File
+	if tempName, temp, err = MakeTempFile(path, "tmp"); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(tempName)
+			tempName = ""
+		}
+	}()
+
+	if err = fn(temp); err != nil {
+		return
+	}
+	if err = temp.Sync(); err != nil {
+		return
+	}
+	if err = temp.Chmod(perm); err != nil {
+		return
+	}
+	err = temp.Close()
+	return
+}
+
+// Creates (and opens) a new file using template (containing path and
+// beginning of file name) and suffix (containing a new suffix to which a
+// number is added). Returns the files name, a pointer to the open file,
+// and any error.
+func MakeTempFile(template, suffix string) (fname string, file *os.File, err error) {
+	template2 := template + "." + suffix
+	for i := 0; i <= maxTempTries; i++ {
+		fname = template2 + strconv.Itoa(i)
+		file, err = os.OpenFile(fname, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		err = fmt.Errorf("could not create temp file based on \"%s\"", template)
+	}
+
+	return
+}
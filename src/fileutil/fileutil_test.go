@@ -0,0 +1,220 @@
+/*
+This file includes tests for the fileutil package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package fileutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("abc"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	err := AtomicRewrite(path, func(w io.Writer, r io.Reader) error {
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			return err
+		}
+		_, err := w.Write(bytes.ToUpper(buf.Bytes()))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contents, _ := os.ReadFile(path); string(contents) != "ABC" {
+		t.Error(fmt.Sprintf("expected \"ABC\", got %q", contents))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Error(fmt.Sprintf("expected permissions 0640, got %o", info.Mode().Perm()))
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Error(fmt.Sprintf("expected only the rewritten file to remain, found %d entries", len(entries)))
+	}
+}
+
+func TestAtomicRewriteKeepsSpecialBits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("abc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	want := os.FileMode(0755) | os.ModeSetuid | os.ModeSetgid
+	if err := os.Chmod(path, want); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode()&want != want {
+		t.Skip("the file system does not keep setuid and setgid bits")
+	}
+
+	if _, err := AtomicRewriteBackup(path, func(w io.Writer, r io.Reader) error {
+		_, err := io.Copy(w, r)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != want {
+		t.Error(fmt.Sprintf("expected mode %v, got %v", want, info.Mode()))
+	}
+}
+
+func TestAtomicRewriteFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failure")
+	err := AtomicRewrite(path, func(w io.Writer, r io.Reader) error {
+		w.Write([]byte("partial"))
+		return failure
+	})
+	if err != failure {
+		t.Error(fmt.Sprintf("expected error %v, got %v", failure, err))
+	}
+
+	if contents, _ := os.ReadFile(path); string(contents) != "abc" {
+		t.Error(fmt.Sprintf("original was modified: %q", contents))
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Error(fmt.Sprintf("expected the temporary file to be removed, found %d entries", len(entries)))
+	}
+}
+
+func TestAtomicRewriteBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := AtomicRewriteBackup(path, func(w io.Writer, r io.Reader) error {
+		_, err := w.Write([]byte("xyz"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != path+".backup0" {
+		t.Error(fmt.Sprintf("unexpected backup name %s", backup))
+	}
+	if contents, _ := os.ReadFile(backup); string(contents) != "abc" {
+		t.Error(fmt.Sprintf("backup holds %q, expected \"abc\"", contents))
+	}
+	if contents, _ := os.ReadFile(path); string(contents) != "xyz" {
+		t.Error(fmt.Sprintf("rewritten file holds %q, expected \"xyz\"", contents))
+	}
+}
+
+func TestAtomicCreate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results")
+
+	err := AtomicCreate(path, 0640, func(w io.Writer) error {
+		_, err := w.Write([]byte("first"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents, _ := os.ReadFile(path); string(contents) != "first" {
+		t.Error(fmt.Sprintf("expected \"first\", got %q", contents))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Error(fmt.Sprintf("expected permissions 0640, got %o", info.Mode().Perm()))
+	}
+
+	failure := errors.New("failure")
+	err = AtomicCreate(path, 0600, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failure
+	})
+	if err != failure {
+		t.Error(fmt.Sprintf("expected error %v, got %v", failure, err))
+	}
+	if contents, _ := os.ReadFile(path); string(contents) != "first" {
+		t.Error(fmt.Sprintf("failed create changed the file to %q", contents))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Error(fmt.Sprintf("expected only the created file to remain, found %d entries", len(entries)))
+	}
+}
+
+func TestCopyFileSparse(t *testing.T) {
+	dir := t.TempDir()
+	src, err := os.Create(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	// data, a hole, more data, and a final hole
+	if _, err = src.WriteAt([]byte("start"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = src.WriteAt([]byte("middle"), 3<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err = src.Truncate(5 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	var done, total int64 = 5 << 20, 5 << 20 // nothing is reported for a shared copy
+	err = CopyFileProgress(dst, src, func(d, t int64) {
+		done, total = d, t
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done != 5<<20 || total != 5<<20 {
+		t.Error(fmt.Sprintf("expected the copy to end at 5242880 of 5242880; got %d of %d", done, total))
+	}
+
+	want, _ := os.ReadFile(src.Name())
+	got, _ := os.ReadFile(dst.Name())
+	if !bytes.Equal(got, want) {
+		t.Error(fmt.Sprintf("the copy differs from the original; got %d bytes, expected %d", len(got), len(want)))
+	}
+}
+
+func TestMakeNumberedFile(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "data")
+	for _, name := range []string{"data.backup0", "data.backup2", "data.backupx", "data.backup02"} {
+		if err := os.WriteFile(filepath.Join(filepath.Dir(template), name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name, f, err := MakeNumberedFile(template, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if name != template+".backup3" {
+		t.Error(fmt.Sprintf("expected %s.backup3, got %s", template, name))
+	}
+
+	names, err := NumberedFiles(template, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != fmt.Sprintf("[%[1]s.backup0 %[1]s.backup2 %[1]s.backup3]", template) {
+		t.Error(fmt.Sprintf("unexpected numbered files %v", names))
+	}
+}
@@ -0,0 +1,75 @@
+/*
+This file implements numbered files (e.g., data.backup0, data.backup1),
+numbered in the order they are made, so that the oldest may be found and
+removed.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Returns the names of the existing files named template.suffixN (e.g.,
+// data.backup3 for template data and suffix backup), in ascending order of
+// N.
+func NumberedFiles(template, suffix string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(template))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(template) + "." + suffix
+	var numbers []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(name[len(prefix):])
+		if err == nil && n >= 0 && strconv.Itoa(n) == name[len(prefix):] {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	names := make([]string, len(numbers))
+	for i, n := range numbers {
+		names[i] = template + "." + suffix + strconv.Itoa(n)
+	}
+	return names, nil
+}
+
+// Creates (and opens) a new file named template.suffixN, where N is one
+// more than that of the highest numbered such file (0 if there is none),
+// so that the numbers follow the order the files were made. Returns the
+// file's name, a pointer to the open file, and any error.
+func MakeNumberedFile(template, suffix string) (fname string, file *os.File, err error) {
+	existing, err := NumberedFiles(template, suffix)
+	if err != nil {
+		return "", nil, err
+	}
+	next := 0
+	if len(existing) > 0 {
+		last := existing[len(existing)-1]
+		next, _ = strconv.Atoi(last[len(template)+1+len(suffix):])
+		next++
+	}
+
+	for i := 0; i <= maxTempTries; i++ {
+		fname = template + "." + suffix + strconv.Itoa(next+i)
+		file, err = os.OpenFile(fname, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+		if err == nil {
+			return
+		}
+	}
+	return "", nil, fmt.Errorf("could not create a numbered file based on \"%s\"", template)
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+/*
+This file implements the replacing of a file by its rewritten copy on
+systems where a file may be renamed over another that is open.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package fileutil
+
+import (
+	"os"
+)
+
+// Replaces the file at path with the file tempName. If backupName is not
+// empty, the original is first renamed to it, and is put back should the
+// replacement fail.
+func replaceFile(path, tempName, backupName string) error {
+	if backupName != "" {
+		if err := os.Rename(path, backupName); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tempName, path); err != nil {
+		if backupName != "" {
+			os.Rename(backupName, path)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+This file implements the replacing of a file by its rewritten copy on
+Windows, where renaming the original out of the way is not reliable.
+ReplaceFile swaps in the copy and, if asked, keeps the original under the
+backup name, in one step.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package fileutil
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const replacefile_ignore_merge_errors = 0x2 // REPLACEFILE_IGNORE_MERGE_ERRORS
+
+var procReplaceFile = syscall.NewLazyDLL("kernel32.dll").NewProc("ReplaceFileW")
+
+// Replaces the file at path with the file tempName. If backupName is not
+// empty, the original is kept under it; the file already there, made to
+// reserve the name, is removed for ReplaceFile to create anew.
+func replaceFile(path, tempName, backupName string) error {
+	if backupName == "" {
+		return os.Rename(tempName, path)
+	}
+	os.Remove(backupName)
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	tempPtr, err := syscall.UTF16PtrFromString(tempName)
+	if err != nil {
+		return err
+	}
+	backupPtr, err := syscall.UTF16PtrFromString(backupName)
+	if err != nil {
+		return err
+	}
+
+	r, _, e := procReplaceFile.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(tempPtr)),
+		uintptr(unsafe.Pointer(backupPtr)),
+		replacefile_ignore_merge_errors, 0, 0)
+	if r == 0 {
+		return &os.LinkError{Op: "replace", Old: tempName, New: path, Err: e}
+	}
+	return nil
+}
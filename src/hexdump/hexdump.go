@@ -0,0 +1,196 @@
+/*
+This package renders a window of bytes around an offset in hex+ASCII, in
+the style of xxd, with the bytes of a match highlighted. It is used by the
+sift tool and may be used by library consumers building reports.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package hexdump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const (
+	defaultWidth = 16
+	defaultGroup = 2
+)
+
+// Options that control how a dump is rendered. The zero value gives
+// xxd-like output of 16 bytes per line in groups of 2 bytes.
+//
+// If HighlightOn is empty, matched bytes are indicated by a line of carets
+// beneath each line that contains them; otherwise HighlightOn and
+// HighlightOff (e.g., terminal color escapes) are written around each run
+// of matched bytes in both the hex and ASCII columns.
+type Options struct {
+	Width        int
+	Group        int
+	HighlightOn  string
+	HighlightOff string
+}
+
+// Writes data to w in hex+ASCII form. base is the offset of data[0] within
+// the input it came from and is used both for the address column and to
+// align lines on multiples of the width. The matchLen bytes starting at
+// data[matchStart] are highlighted. opts may be nil.
+func Dump(w io.Writer, data []byte, base uint64, matchStart, matchLen int, opts *Options) error {
+	width, group := defaultWidth, defaultGroup
+	var on, off string
+	if opts != nil {
+		if opts.Width > 0 {
+			width = opts.Width
+		}
+		if opts.Group > 0 {
+			group = opts.Group
+		}
+		on, off = opts.HighlightOn, opts.HighlightOff
+	}
+
+	out := bufio.NewWriter(w)
+	lead := int(base % uint64(width))
+	lineBase := base - uint64(lead)
+
+	for pos := -lead; pos < len(data); pos += width {
+		var carets []byte
+		fmt.Fprintf(out, "%08x: ", lineBase)
+
+		lit := false
+		for i := 0; i < width; i++ {
+			if i > 0 && i%group == 0 {
+				out.WriteByte(' ')
+				carets = append(carets, ' ')
+			}
+			idx := pos + i
+			if idx < 0 || idx >= len(data) {
+				if lit {
+					out.WriteString(off)
+					lit = false
+				}
+				out.WriteString("  ")
+				carets = append(carets, ' ', ' ')
+				continue
+			}
+			hit := idx >= matchStart && idx < matchStart+matchLen
+			if on != "" && hit != lit {
+				if hit {
+					out.WriteString(on)
+				} else {
+					out.WriteString(off)
+				}
+				lit = hit
+			}
+			fmt.Fprintf(out, "%02x", data[idx])
+			if hit {
+				carets = append(carets, '^', '^')
+			} else {
+				carets = append(carets, ' ', ' ')
+			}
+		}
+		if lit {
+			out.WriteString(off)
+			lit = false
+		}
+
+		out.WriteString("  ")
+		carets = append(carets, ' ', ' ')
+		for i := 0; i < width; i++ {
+			idx := pos + i
+			if idx >= len(data) {
+				break
+			} else if idx < 0 {
+				out.WriteByte(' ')
+				carets = append(carets, ' ')
+				continue
+			}
+			hit := idx >= matchStart && idx < matchStart+matchLen
+			if on != "" && hit != lit {
+				if hit {
+					out.WriteString(on)
+				} else {
+					out.WriteString(off)
+				}
+				lit = hit
+			}
+			out.WriteByte(printable(data[idx]))
+			if hit {
+				carets = append(carets, '^')
+			} else {
+				carets = append(carets, ' ')
+			}
+		}
+		if lit {
+			out.WriteString(off)
+		}
+		out.WriteByte('\n')
+
+		if on == "" && overlaps(pos, pos+width, matchStart, matchStart+matchLen) {
+			fmt.Fprintf(out, "%10s%s\n", "", trimRight(carets))
+		}
+		lineBase += uint64(width)
+	}
+
+	return out.Flush()
+}
+
+// Reads the bytes surrounding a match of matchLen bytes at offset within r,
+// including up to before bytes preceding it and after bytes following it.
+// The window is clipped to the start of the input and, if size is not
+// negative, to its end. Returns the bytes read and the offset of the first
+// of them.
+func ReadWindow(r io.ReaderAt, size, offset int64, matchLen, before, after int) (data []byte, start int64, err error) {
+	start = offset - int64(before)
+	if start < 0 {
+		start = 0
+	}
+	end := offset + int64(matchLen) + int64(after)
+	if size >= 0 && end > size {
+		end = size
+	}
+	if end < start {
+		end = start
+	}
+
+	data = make([]byte, end-start)
+	n, err := r.ReadAt(data, start)
+	if err == io.EOF {
+		err = nil
+	}
+	return data[:n], start, err
+}
+
+// Reads a window around the match at offset within r (see ReadWindow) and
+// writes it to w with the match highlighted (see Dump).
+func DumpAt(w io.Writer, r io.ReaderAt, size, offset int64, matchLen, context int, opts *Options) error {
+	data, start, err := ReadWindow(r, size, offset, matchLen, context, context)
+	if err != nil {
+		return err
+	}
+	return Dump(w, data, uint64(start), int(offset-start), matchLen, opts)
+}
+
+// Returns the byte if it is printable ASCII, otherwise '.'.
+func printable(b byte) byte {
+	if b >= 0x20 && b < 0x7f {
+		return b
+	}
+	return '.'
+}
+
+// Does the half-open range [a1, a2) overlap [b1, b2)?
+func overlaps(a1, a2, b1, b2 int) bool {
+	return a1 < b2 && b1 < a2
+}
+
+// Returns b without trailing spaces.
+func trimRight(b []byte) []byte {
+	l := len(b)
+	for l > 0 && b[l-1] == ' ' {
+		l--
+	}
+	return b[:l]
+}
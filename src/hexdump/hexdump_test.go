@@ -0,0 +1,62 @@
+/*
+This file includes tests for the hexdump package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package hexdump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDumpCarets(t *testing.T) {
+	var buf bytes.Buffer
+	data := []byte("here is a simple example\x00\x01")
+	if err := Dump(&buf, data, 0, 17, 7, nil); err != nil {
+		t.Fatal(err)
+	}
+	expect := "" +
+		"00000000: 6865 7265 2069 7320 6120 7369 6d70 6c65  here is a simple\n" +
+		"00000010: 2065 7861 6d70 6c65 0001                  example..\n" +
+		"            ^^ ^^^^ ^^^^ ^^^^                       ^^^^^^^\n"
+	if buf.String() != expect {
+		t.Error(fmt.Sprintf("expected\n%s\ngot\n%s", expect, buf.String()))
+	}
+}
+
+func TestDumpAligned(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Dump(&buf, []byte("abc"), 0x1e, 1, 1, &Options{HighlightOn: "<", HighlightOff: ">"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := "" +
+		"00000010:                                    61<62>                a<b>\n" +
+		"00000020: 63                                       c\n"
+	if buf.String() != expect {
+		t.Error(fmt.Sprintf("expected\n%q\ngot\n%q", expect, buf.String()))
+	}
+}
+
+func TestReadWindow(t *testing.T) {
+	r := strings.NewReader("0123456789")
+	data, start, err := ReadWindow(r, 10, 2, 2, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 0 || string(data) != "01234567" {
+		t.Error(fmt.Sprintf("expected window \"01234567\" at 0, got %q at %d", data, start))
+	}
+	data, start, err = ReadWindow(r, -1, 8, 1, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 7 || string(data) != "789" {
+		t.Error(fmt.Sprintf("expected window \"789\" at 7, got %q at %d", data, start))
+	}
+}
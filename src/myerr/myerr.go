@@ -7,12 +7,59 @@ import (
 
 var exitCode int = 0
 
+// how much is displayed besides errors
+type Level int
+
+const (
+	LevelError Level = iota // errors only
+	LevelWarn               // warnings as well (the default)
+	LevelInfo               // what was done to each input
+	LevelDebug              // each decision made, e.g., an input skipped or filtered out
+)
+
+var verbosity Level = LevelWarn
+
+// set how much Warn, Info, and Debug display
+func SetVerbosity(level Level) {
+	verbosity = level
+}
+
+// would a message at level be displayed?
+func Enabled(level Level) bool {
+	return level <= verbosity
+}
+
+// display a message at level, after prefix, using fmt.Printf style args to
+// stderr if the verbosity allows it
+func logAt(level Level, prefix, formatString string, elements ...interface{}) {
+	if Enabled(level) {
+		MyError(prefix+formatString, elements...)
+	}
+}
+
+func Warn(formatString string, elements ...interface{}) {
+	logAt(LevelWarn, "warning: ", formatString, elements...)
+}
+
+func Info(formatString string, elements ...interface{}) {
+	logAt(LevelInfo, "info: ", formatString, elements...)
+}
+
+func Debug(formatString string, elements ...interface{}) {
+	logAt(LevelDebug, "debug: ", formatString, elements...)
+}
+
 // display an error using fmt.Printf style args to stderr
 func MyError(formatString string, elements ...interface{}) {
 	fmt.Fprintf(os.Stderr, formatString, elements...)
 	fmt.Fprintln(os.Stderr)
 }
 
+// set the status code to exit with, without displaying an error
+func SetExitCode(code int) {
+	exitCode = code
+}
+
 func MyFatal(code int, formatString string, elements ...interface{}) {
 	exitCode = code
 	MyError(formatString, elements...)
@@ -0,0 +1,105 @@
+/*
+This file implements the entry points for programs that patch files
+without the swap tool: resolving a FilePlan's anchors, building a plan
+from it (as read from a plan file), verifying the plan, and applying it.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package patch
+
+import (
+	"fmt"
+	"io"
+)
+
+// Returns the plan for the changes fp lists. An edit that does not give
+// the bytes it expects takes defaults' Expected and Mask; one that does not
+// give its replacement takes defaults' Replacement. defaults' Offset is
+// not used.
+func BuildPlan(fp *FilePlan, defaults Edit) (*Plan, error) {
+	p := &Plan{Edits: make([]Edit, 0, len(fp.Edits))}
+	for _, e := range fp.Edits {
+		if e.Anchor != "" {
+			return nil, fmt.Errorf("patch: offset %d is relative to the anchor %q, which has not been resolved", e.Offset, e.Anchor)
+		}
+		edit := Edit{Offset: e.Offset, Expected: e.Expected, Replacement: e.Replacement, Mask: e.Mask}
+		if len(edit.Expected) == 0 {
+			edit.Expected, edit.Mask = defaults.Expected, defaults.Mask
+		}
+		if len(edit.Replacement) == 0 {
+			edit.Replacement = defaults.Replacement
+		}
+		if len(edit.Replacement) == 0 {
+			return nil, fmt.Errorf("patch: no replacement for offset %d", e.Offset)
+		}
+		if len(edit.Expected) != 0 && len(edit.Expected) != len(edit.Replacement) {
+			return nil, fmt.Errorf("patch: the %d bytes expected at offset %d are not the same size as the %d of the replacement", len(edit.Expected), e.Offset, len(edit.Replacement))
+		}
+		if edit.Mask != nil && len(edit.Mask) != len(edit.Expected) {
+			return nil, fmt.Errorf("patch: the mask at offset %d is %d bytes, but %d are expected", e.Offset, len(edit.Mask), len(edit.Expected))
+		}
+		p.Edits = append(p.Edits, edit)
+	}
+	p.Sort()
+	return p, nil
+}
+
+// Returns a copy of fp in which each edit's offset relative to an anchor
+// is made absolute. find returns the offset of the first occurrence of an
+// anchor's bytes in the file, and whether they were found at all; it is
+// called once for each anchor used.
+func ResolveAnchors(fp *FilePlan, find func(needle []byte) (offset uint64, found bool, err error)) (*FilePlan, error) {
+	resolved := *fp
+	resolved.Edits = append([]FileEdit(nil), fp.Edits...)
+	resolved.Anchors = nil
+	found := make(map[string]uint64)
+	for i, e := range resolved.Edits {
+		if e.Anchor == "" {
+			continue
+		}
+		base, ok := found[e.Anchor]
+		if !ok {
+			needle, defined := fp.Anchors[e.Anchor]
+			if !defined || len(needle) == 0 {
+				return nil, fmt.Errorf("patch: the anchor %q is not defined", e.Anchor)
+			}
+			var err error
+			if base, ok, err = find(needle); err != nil {
+				return nil, err
+			} else if !ok {
+				return nil, fmt.Errorf("patch: the anchor %q (%x) was not found in %s", e.Anchor, []byte(needle), fp.Path)
+			}
+			found[e.Anchor] = base
+		}
+		resolved.Edits[i].Offset, resolved.Edits[i].Anchor = base+e.Offset, ""
+	}
+	return &resolved, nil
+}
+
+// Checks p against r without changing anything, as Plan.Verify does.
+func VerifyPlan(p *Plan, r io.ReaderAt) ([]Mismatch, error) {
+	return p.Verify(r)
+}
+
+// Applies p, verifying each edit against r and writing it to w, which may
+// be the same as r (as for Plan.Apply) or a copy of it being made. Returns
+// the offsets written and the mismatches.
+func ApplyPlan(p *Plan, r io.ReaderAt, w io.WriterAt) (applied []uint64, mismatches []Mismatch, err error) {
+	for _, edit := range p.Edits {
+		var m *Mismatch
+		if m, err = check(r, edit); err != nil {
+			return
+		}
+		if m != nil {
+			mismatches = append(mismatches, *m)
+			continue
+		}
+		if _, err = w.WriteAt(edit.Replacement, int64(edit.Offset)); err != nil {
+			return
+		}
+		applied = append(applied, edit.Offset)
+	}
+	return
+}
@@ -0,0 +1,152 @@
+/*
+This package implements the "write these bytes at these offsets, provided
+the bytes already there are the expected ones" logic of the swap tool, so
+that programs can apply patches without running the swap binary.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The error returned when a plan has nothing to write.
+var ErrEmptyReplacement = errors.New("patch: the replacement may not be empty")
+
+// Something that can be both read and written at arbitrary offsets, such as
+// an *os.File.
+type ReadWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// A single change: write Replacement at Offset. If Expected is not empty,
+// the change is only made if the bytes at Offset equal Expected. If Mask
+// is not nil, only the bits set in it are compared, so that a 0 byte in
+// Mask matches any byte.
+type Edit struct {
+	Offset      uint64
+	Expected    []byte
+	Replacement []byte
+	Mask        []byte
+}
+
+// A set of changes to apply to one file, kept in ascending offset order.
+type Plan struct {
+	Edits []Edit
+}
+
+// An offset at which the bytes found were not the ones expected.
+type Mismatch struct {
+	Offset   uint64
+	Expected []byte
+	Found    []byte
+}
+
+//// TYPE editSlice ////
+
+type editSlice []Edit
+
+func (d editSlice) Len() int {
+	return len(d)
+}
+
+func (d editSlice) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+}
+
+func (d editSlice) Less(i, j int) bool {
+	return d[i].Offset < d[j].Offset
+}
+
+//// FUNCTIONS ////
+
+// Returns a plan that writes replacement at each of offsets. If expected is
+// not empty it must be the same size as replacement, and each write only
+// takes place if the bytes at that offset equal expected.
+func NewPlan(expected, replacement []byte, offsets []uint64) (*Plan, error) {
+	if len(replacement) == 0 {
+		return nil, ErrEmptyReplacement
+	}
+	if len(expected) != 0 && len(expected) != len(replacement) {
+		return nil, fmt.Errorf("patch: expected bytes must be the same size as the replacement; %d is not equal to %d", len(expected), len(replacement))
+	}
+
+	p := &Plan{Edits: make([]Edit, 0, len(offsets))}
+	for _, offset := range offsets {
+		p.Edits = append(p.Edits, Edit{Offset: offset, Expected: expected, Replacement: replacement})
+	}
+	p.Sort()
+	return p, nil
+}
+
+// Puts the edits in ascending offset order.
+func (p *Plan) Sort() {
+	sort.Stable(editSlice(p.Edits))
+}
+
+// Checks every edit that has expected bytes against r without changing
+// anything. Returns the edits whose expected bytes were not found; reading
+// past the end of r counts as a mismatch.
+func (p *Plan) Verify(r io.ReaderAt) (mismatches []Mismatch, err error) {
+	for _, edit := range p.Edits {
+		var m *Mismatch
+		if m, err = check(r, edit); err != nil {
+			return
+		}
+		if m != nil {
+			mismatches = append(mismatches, *m)
+		}
+	}
+	return
+}
+
+// Applies the plan to f. Each edit is verified immediately before it is
+// written; edits whose expected bytes are not found are skipped and
+// reported as mismatches. Returns the offsets written and the mismatches.
+func (p *Plan) Apply(f ReadWriterAt) (applied []uint64, mismatches []Mismatch, err error) {
+	return ApplyPlan(p, f, f)
+}
+
+// Returns a Mismatch if the bytes at the edit's offset are not the ones it
+// expects, nil if they are or if the edit expects nothing.
+func check(r io.ReaderAt, edit Edit) (*Mismatch, error) {
+	if len(edit.Expected) == 0 {
+		return nil, nil
+	}
+
+	found := make([]byte, len(edit.Expected))
+	count, err := r.ReadAt(found, int64(edit.Offset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	found = found[:count]
+	if maskedEqual(found, edit.Expected, edit.Mask) {
+		return nil, nil
+	}
+	return &Mismatch{edit.Offset, edit.Expected, found}, nil
+}
+
+// Are found and expected equal in the bits set in mask? If mask is nil,
+// they must be equal in every bit.
+func maskedEqual(found, expected, mask []byte) bool {
+	if mask == nil {
+		return bytes.Equal(found, expected)
+	}
+	if len(found) != len(expected) || len(mask) != len(expected) {
+		return false
+	}
+	for i := range expected {
+		if found[i]&mask[i] != expected[i]&mask[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+/*
+This file includes tests for the patch package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// an in-memory ReadWriterAt of fixed size
+type memFile []byte
+
+func (m memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m memFile) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(m)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(m[off:], p), nil
+}
+
+func TestNewPlanSorts(t *testing.T) {
+	p, err := NewPlan(nil, []byte("x"), []uint64{9, 3, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expect := range []uint64{3, 5, 9} {
+		if p.Edits[i].Offset != expect {
+			t.Error(fmt.Sprintf("expected edit %d at offset %d, got %d", i, expect, p.Edits[i].Offset))
+		}
+	}
+}
+
+func TestNewPlanErrors(t *testing.T) {
+	if _, err := NewPlan(nil, nil, []uint64{0}); err != ErrEmptyReplacement {
+		t.Error(fmt.Sprintf("expected error %v, got %v", ErrEmptyReplacement, err))
+	}
+	if _, err := NewPlan([]byte("ab"), []byte("abc"), []uint64{0}); err == nil {
+		t.Error("expected an error for differently sized expected and replacement bytes")
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := memFile("to be or not to be, to me")
+	p, err := NewPlan([]byte("be"), []byte("BE"), []uint64{3, 16, 23, 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := p.Verify(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 2 {
+		t.Error(fmt.Sprintf("expected 2 mismatches from Verify, got %d", len(mismatches)))
+	}
+	if string(f) != "to be or not to be, to me" {
+		t.Error("Verify modified its input")
+	}
+
+	applied, mismatches, err := p.Apply(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(applied) != "[3 16]" {
+		t.Error(fmt.Sprintf("expected offsets [3 16] applied, got %v", applied))
+	}
+	if len(mismatches) != 2 || string(mismatches[0].Found) != "me" || string(mismatches[1].Found) != "e" {
+		t.Error(fmt.Sprintf("unexpected mismatches %v", mismatches))
+	}
+	if string(f) != "to BE or not to BE, to me" {
+		t.Error(fmt.Sprintf("unexpected result %q", string(f)))
+	}
+}
+
+func TestApplyMasked(t *testing.T) {
+	f := memFile("ab1de ab2de xb3de")
+	p := &Plan{}
+	for _, offset := range []uint64{0, 6, 12} {
+		p.Edits = append(p.Edits, Edit{Offset: offset, Expected: []byte("ab?de"), Replacement: []byte("-----"), Mask: []byte{0xff, 0xff, 0, 0xff, 0xff}})
+	}
+	applied, mismatches, err := p.Apply(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(applied) != "[0 6]" || len(mismatches) != 1 || mismatches[0].Offset != 12 {
+		t.Error(fmt.Sprintf("expected offsets [0 6] applied and 12 skipped; got %v and %v", applied, mismatches))
+	}
+}
+
+func TestApplyUnverified(t *testing.T) {
+	f := memFile("0000000000")
+	p, err := NewPlan(nil, []byte("11"), []uint64{0, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = p.Apply(f); err != nil {
+		t.Fatal(err)
+	}
+	if string(f) != "1100000011" {
+		t.Error(fmt.Sprintf("unexpected result %q", string(f)))
+	}
+}
+
+func TestFilePlanRoundTrip(t *testing.T) {
+	modified := time.Date(2012, 6, 30, 18, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	for _, fp := range []*FilePlan{
+		{Path: "a.bin", Size: 100, ModTime: &modified, SHA256: "00ff", Edits: []FileEdit{{3, Hex("ab"), nil, nil, ""}, {40, Hex{0, 0xff}, Hex("zz"), nil, ""}}},
+		{Path: "STDIN", Size: -1},
+	} {
+		if err := WriteFilePlan(&buf, fp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plans, err := ReadFilePlans(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 2 {
+		t.Fatal(fmt.Sprintf("expected 2 plans; got %d", len(plans)))
+	}
+	a := plans[0]
+	if a.Path != "a.bin" || a.Size != 100 || a.ModTime == nil || !a.ModTime.Equal(modified) || a.SHA256 != "00ff" || len(a.Edits) != 2 {
+		t.Error(fmt.Sprintf("plan did not survive the round trip; got %+v", a))
+	} else if a.Edits[1].Offset != 40 || !bytes.Equal(a.Edits[1].Expected, []byte{0, 0xff}) || string(a.Edits[1].Replacement) != "zz" {
+		t.Error(fmt.Sprintf("edit did not survive the round trip; got %+v", a.Edits[1]))
+	}
+	if plans[1].ModTime != nil || plans[1].Size != -1 {
+		t.Error(fmt.Sprintf("expected no modification time and a size of -1; got %+v", plans[1]))
+	}
+}
+
+func TestReadFilePlansVersion(t *testing.T) {
+	if _, err := ReadFilePlans(strings.NewReader(`{"version":3,"path":"x","edits":[]}`)); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+}
+
+func TestWriteFileResult(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewFileResult("a.bin", nil, []Mismatch{{7, []byte("ab"), []byte("a")}}, errors.New("disk full"))
+	if err := WriteFileResult(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"version":2,"path":"a.bin","applied":[],"skipped":[{"offset":7,"expected":"6162","found":"61"}],"error":"disk full"}` + "\n"
+	if buf.String() != expected {
+		t.Error(fmt.Sprintf("expected %s; got %s", expected, buf.String()))
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	fp := &FilePlan{Path: "a.bin", Size: -1, Edits: []FileEdit{{9, nil, nil, nil, ""}, {2, Hex("xy"), Hex("XY"), nil, ""}}}
+	p, err := BuildPlan(fp, Edit{Expected: []byte("ab"), Replacement: []byte("AB")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Edits) != 2 || p.Edits[0].Offset != 2 || string(p.Edits[0].Replacement) != "XY" || string(p.Edits[1].Expected) != "ab" || string(p.Edits[1].Replacement) != "AB" {
+		t.Error(fmt.Sprintf("unexpected plan %+v", p.Edits))
+	}
+
+	if _, err = BuildPlan(fp, Edit{}); err == nil {
+		t.Error("expected an error for an edit with no replacement")
+	}
+	if _, err = BuildPlan(fp, Edit{Expected: []byte("abc"), Replacement: []byte("AB")}); err == nil {
+		t.Error("expected an error for expected bytes of a different size")
+	}
+}
+
+func TestResolveAnchors(t *testing.T) {
+	fp := &FilePlan{Path: "a.bin", Size: -1, Anchors: map[string]Hex{"header": Hex("HDR")}, Edits: []FileEdit{{Offset: 5}, {Offset: 4, Anchor: "header"}, {Offset: 8, Anchor: "header"}}}
+	if _, err := BuildPlan(fp, Edit{Replacement: []byte("x")}); err == nil {
+		t.Error("expected an error for an unresolved anchor")
+	}
+
+	calls := 0
+	find := func(needle []byte) (uint64, bool, error) {
+		calls++
+		return 100, string(needle) == "HDR", nil
+	}
+	resolved, err := ResolveAnchors(fp, find)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := resolved.Edits
+	if calls != 1 || e[0].Offset != 5 || e[1].Offset != 104 || e[2].Offset != 108 || e[1].Anchor != "" || fp.Edits[1].Offset != 4 {
+		t.Error(fmt.Sprintf("unexpected edits %+v after %d searches", e, calls))
+	}
+
+	fp.Anchors["header"] = Hex("XXX")
+	if _, err = ResolveAnchors(fp, find); err == nil {
+		t.Error("expected an error for an anchor not found")
+	}
+	fp.Edits[1].Anchor = "footer"
+	if _, err = ResolveAnchors(fp, find); err == nil {
+		t.Error("expected an error for an undefined anchor")
+	}
+}
+
+func TestApplyPlanToCopy(t *testing.T) {
+	original := memFile("abcabc")
+	copied := memFile("abcabc")
+	p, err := NewPlan([]byte("bc"), []byte("XY"), []uint64{1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, mismatches, err := ApplyPlan(p, original, copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || len(mismatches) != 0 || string(copied) != "aXYaXY" || string(original) != "abcabc" {
+		t.Error(fmt.Sprintf("unexpected result %q (applied %v, mismatches %v)", string(copied), applied, mismatches))
+	}
+}
@@ -0,0 +1,139 @@
+/*
+This file defines the versioned JSON plan format that sift writes (-swap)
+and swap reads. A plan file is a sequence of JSON objects, one per file,
+each listing the offsets to change together with the bytes expected at
+them and the size and modification time the file had when it was scanned,
+so that the changes can be verified before they are applied. The results
+of applying a plan (swap -report) are written in the same manner.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package patch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The version of the plan format written by this package.
+const PlanVersion = 2
+
+// Bytes that are written to and read from JSON as a hexadecimal string.
+type Hex []byte
+
+// The changes planned for one file. Size is -1 and ModTime nil when they
+// were not known (e.g., for the standard input); SHA256, the file's
+// checksum in hexadecimal, is empty unless it was taken. Anchors names
+// the bytes whose first occurrence in the file an edit's offset may be
+// relative to (see ResolveAnchors).
+type FilePlan struct {
+	Version int            `json:"version"`
+	Path    string         `json:"path"`
+	Size    int64          `json:"size"`
+	ModTime *time.Time     `json:"mtime,omitempty"`
+	SHA256  string         `json:"sha256,omitempty"`
+	Anchors map[string]Hex `json:"anchors,omitempty"`
+	Edits   []FileEdit     `json:"edits"`
+}
+
+// A change within a FilePlan. Expected is what was found at Offset; if
+// Replacement is empty, the replacement is supplied when the plan is
+// applied. Mask is as for Edit. If Anchor is set, Offset is relative to
+// where that anchor of the FilePlan is found.
+type FileEdit struct {
+	Offset      uint64 `json:"offset"`
+	Expected    Hex    `json:"expected,omitempty"`
+	Replacement Hex    `json:"replacement,omitempty"`
+	Mask        Hex    `json:"mask,omitempty"`
+	Anchor      string `json:"anchor,omitempty"`
+}
+
+// The outcome of applying the changes planned for one file. Error is set
+// if the file could not be changed at all, or not completely.
+type FileResult struct {
+	Version int           `json:"version"`
+	Path    string        `json:"path"`
+	Applied []uint64      `json:"applied"`
+	Skipped []SkippedEdit `json:"skipped"`
+	Error   string        `json:"error,omitempty"`
+
+	// the SHA-256 of the file before and after, in hexadecimal, if taken
+	SHA256Before string `json:"sha256_before,omitempty"`
+	SHA256After  string `json:"sha256_after,omitempty"`
+}
+
+// A change within a FileResult that was skipped because the bytes found at
+// Offset were not the ones expected.
+type SkippedEdit struct {
+	Offset   uint64 `json:"offset"`
+	Expected Hex    `json:"expected"`
+	Found    Hex    `json:"found"`
+}
+
+//// TYPE Hex ////
+
+func (h Hex) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+func (h *Hex) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+//// FUNCTIONS ////
+
+// Writes fp to w as a single line of JSON, setting its version.
+func WriteFilePlan(w io.Writer, fp *FilePlan) error {
+	fp.Version = PlanVersion
+	return json.NewEncoder(w).Encode(fp)
+}
+
+// Returns the result of applying changes to the file at path, given what
+// Apply (or the like) returned.
+func NewFileResult(path string, applied []uint64, mismatches []Mismatch, err error) *FileResult {
+	r := &FileResult{Path: path, Applied: applied, Skipped: make([]SkippedEdit, 0, len(mismatches))}
+	if r.Applied == nil {
+		r.Applied = []uint64{}
+	}
+	for _, m := range mismatches {
+		r.Skipped = append(r.Skipped, SkippedEdit{m.Offset, m.Expected, m.Found})
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Writes r to w as a single line of JSON, setting its version.
+func WriteFileResult(w io.Writer, r *FileResult) error {
+	r.Version = PlanVersion
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Reads every file plan from r. Plans of a version this package does not
+// understand are an error.
+func ReadFilePlans(r io.Reader) (plans []*FilePlan, err error) {
+	dec := json.NewDecoder(r)
+	for {
+		fp := new(FilePlan)
+		if err = dec.Decode(fp); err == io.EOF {
+			return plans, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if fp.Version != PlanVersion {
+			return nil, fmt.Errorf("patch: plan for %q is of version %d; only version %d is understood", fp.Path, fp.Version, PlanVersion)
+		}
+		plans = append(plans, fp)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+This file implements the searching of the entries of tar and zip archives
+(the -archives flag). A match within an entry is reported with the path
+archive!entry.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"decompress"
+	"io"
+	"strings"
+	"substr"
+)
+
+const (
+	archive_none = iota
+	archive_tar
+	archive_zip
+)
+
+// Returns the kind of archive path names, judging by its extension.
+func archiveKind(path string) int {
+	lower := strings.ToLower(path)
+	for _, suffix := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz"} {
+		if strings.HasSuffix(lower, suffix) {
+			return archive_tar
+		}
+	}
+	for _, suffix := range []string{".zip", ".jar", ".war", ".apk"} {
+		if strings.HasSuffix(lower, suffix) {
+			return archive_zip
+		}
+	}
+	return archive_none
+}
+
+// Searches each regular file within the archive at path, writing the
+// results to out. Returns false if path is not an archive.
+func processArchive(path string, out io.Writer) bool {
+	switch archiveKind(path) {
+	case archive_tar:
+		processTar(path, out)
+	case archive_zip:
+		processZip(path, out)
+	default:
+		return false
+	}
+	return true
+}
+
+func processTar(path string, out io.Writer) {
+	f, err := openFile(path)
+	if err != nil {
+		fileError(path, "open", err, "warning: could not open %s; skipping", path)
+		return
+	}
+	defer closeFile(f)
+
+	r, _, err := decompress.NewReader(f)
+	if err != nil {
+		fileError(path, "decompress", err, "warning: could not decompress %s; skipping -- %s", path, err)
+		return
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			searchError(path, err)
+			return
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		processHaystack(&input{path + "!" + header.Name, substr.NewHaystackReader(tr), nil, -1, out, nil, 0, 0})
+	}
+}
+
+func processZip(path string, out io.Writer) {
+	f, err := openFile(path)
+	if err != nil {
+		fileError(path, "open", err, "warning: could not open %s; skipping", path)
+		return
+	}
+	defer closeFile(f)
+
+	var zr *zip.Reader
+	info, err := f.Stat()
+	if err == nil {
+		zr, err = zip.NewReader(f, info.Size())
+	}
+	if err != nil {
+		fileError(path, "open", err, "warning: could not open %s as a zip archive; skipping -- %s", path, err)
+		return
+	}
+
+	for _, entry := range zr.File {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			searchError(path+"!"+entry.Name, err)
+			continue
+		}
+		processHaystack(&input{path + "!" + entry.Name, substr.NewHaystackReader(rc), nil, -1, out, nil, 0, 0})
+		rc.Close()
+	}
+}
@@ -0,0 +1,92 @@
+/*
+This file implements the detection of binary inputs and their handling
+under the -binary flag.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"myerr"
+	"substr"
+)
+
+// how many leading bytes of an input are checked for a NUL
+const binary_check_size = 8 * 1024
+
+const (
+	binary_search = "search" // search binary inputs like any other
+	binary_skip   = "skip"   // do not search binary inputs
+	binary_list   = "list"   // only report whether a binary input matches
+)
+
+// Checks that -binary names a known mode.
+func checkBinaryMode() {
+	switch *binaryMode {
+	case binary_search, binary_skip, binary_list:
+	default:
+		myerr.MyImmediateFatal(status_fatal_error, "error: -binary must be one of search, skip, or list; got \"%s\"", *binaryMode)
+	}
+}
+
+// Is input likely binary, i.e., is there a NUL byte within its first
+// block? The first block of a stream is read ahead, and input's haystack
+// replaced so that it is still searched in full.
+func isBinary(input *input) (bool, error) {
+	head := make([]byte, binary_check_size)
+	if input.ra != nil {
+		n, err := input.ra.ReadAt(head, 0)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		return bytes.IndexByte(head[:n], 0) >= 0, nil
+	}
+
+	r, err := input.haystack.Reader()
+	if err != nil {
+		return false, err
+	}
+	in := bufio.NewReaderSize(r, binary_check_size)
+	input.haystack = substr.NewHaystackReader(in)
+	peeked, err := in.Peek(binary_check_size)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return false, err
+	}
+	return bytes.IndexByte(peeked, 0) >= 0, nil
+}
+
+// Applies -binary to input. Returns true if input has been dealt with and
+// is not to be searched further.
+func handleBinary(input *input) bool {
+	if *binaryMode == binary_search {
+		return false
+	}
+
+	binary, err := isBinary(input)
+	if err != nil {
+		searchError(input.path, err)
+		return true
+	}
+	if !binary {
+		return false
+	}
+
+	if *binaryMode == binary_skip {
+		myerr.Debug("%s: skipped; binary (see -binary)", input.path)
+		return true
+	}
+
+	// binary_list
+	if matched, err := inputMatches(input); err != nil {
+		searchError(input.path, err)
+	} else if matched {
+		fmt.Fprintf(input.out, "binary file %s matches%s", colorPath(input.path), recordEnd())
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+/*
+This file implements sift's time budgets: -timeout-per-file, which limits
+the time spent searching any one input, and -deadline, which limits the
+whole run. An input whose time runs out is reported as not completed, as
+is every input not yet searched once the deadline passes; either way the
+run exits with status_file_errors. Time is checked as data is read, so a
+mapped file (-mmap) is not mapped when a budget is given, and a read that
+waits for data (e.g., from a pipe) is cut short when the time runs out.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// the error reported by a read once an input's time has run out
+var errTimedOut = errors.New("ran out of time before the search completed")
+
+// when the run must end (-deadline), or the zero time if it need not
+var deadline time.Time
+
+//// TYPE timedReader ////
+
+// Reads from an input until a given time, after which every read fails
+// with errTimedOut. If the input may wait for data, each read is made by
+// another goroutine, so that it can be abandoned when the time runs out,
+// unless the input's own read deadline can be set instead.
+type timedReader struct {
+	r       io.Reader
+	ra      io.ReaderAt // nil if the input does not allow random access
+	until   time.Time
+	waits   bool             // might a read wait indefinitely for data?
+	pending chan timedResult // the result of a read abandoned, or nil
+}
+
+// The result of a read made by timedReader on another goroutine.
+type timedResult struct {
+	data []byte
+	err  error
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	if time.Now().After(t.until) {
+		return 0, errTimedOut
+	}
+	if !t.waits {
+		n, err := t.r.Read(p)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = errTimedOut
+		}
+		return n, err
+	}
+
+	if t.pending == nil {
+		t.pending = make(chan timedResult, 1)
+		go func(size int) {
+			data := make([]byte, size)
+			n, err := t.r.Read(data)
+			t.pending <- timedResult{data[:n], err}
+		}(len(p))
+	}
+	timer := time.NewTimer(time.Until(t.until))
+	defer timer.Stop()
+	select {
+	case result := <-t.pending:
+		t.pending = nil
+		return copy(p, result.data), result.err
+	case <-timer.C:
+		return 0, errTimedOut
+	}
+}
+
+func (t *timedReader) ReadAt(p []byte, off int64) (int, error) {
+	if time.Now().After(t.until) {
+		return 0, errTimedOut
+	}
+	return t.ra.ReadAt(p, off)
+}
+
+//// FUNCTIONS ////
+
+// Sets the deadline, if -deadline was given, counting from now.
+func startDeadline() {
+	if *deadlineAfter > 0 {
+		deadline = time.Now().Add(*deadlineAfter)
+	}
+}
+
+// Has the deadline passed? If so, nothing more is searched.
+func deadlinePassed() bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// Is any time budget in force?
+func budgeted() bool {
+	return *timeoutPerFile > 0 || !deadline.IsZero()
+}
+
+// Returns r, along with ra if not nil, limited to the time allowed for an
+// input whose search starts now. They are returned as they are if there is
+// no budget.
+func withBudget(r io.Reader, ra io.ReaderAt) (io.Reader, io.ReaderAt) {
+	if !budgeted() {
+		return r, ra
+	}
+
+	until := deadline
+	if *timeoutPerFile > 0 {
+		if fileEnd := time.Now().Add(*timeoutPerFile); until.IsZero() || fileEnd.Before(until) {
+			until = fileEnd
+		}
+	}
+	t := &timedReader{r, ra, until, false, nil}
+	if f, ok := r.(*os.File); ok {
+		// a regular file never waits for data; anything else is cut short
+		// by its read deadline where that can be set
+		info, err := f.Stat()
+		t.waits = (err != nil || !info.Mode().IsRegular()) && f.SetReadDeadline(until) != nil
+	}
+	if ra == nil {
+		return t, nil
+	}
+	return t, t
+}
@@ -0,0 +1,80 @@
+/*
+This file implements sift's carving (-carve): the bytes starting at each
+match reported are written to a file of their own in the -carve-dir
+directory, numbered in the order carved, so that (e.g.) a file found by
+its header can be recovered in the same pass that finds it.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"myerr"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	carveMutex  sync.Mutex
+	carveNumber int // the number of the last file carved
+)
+
+// Is -carve in force?
+func carving() bool {
+	return *carveSize > 0
+}
+
+// Creates -carve-dir if need be, when carving. Exits if it cannot be.
+func setupCarving() {
+	if *carveSize < 0 {
+		myerr.MyImmediateFatal(status_fatal_error, "error: -carve may not be negative")
+	}
+	if carving() {
+		if err := os.MkdirAll(*carveDir, 0755); err != nil {
+			myerr.MyImmediateFatal(status_fatal_error, "error: could not create %s; %s", *carveDir, err)
+		}
+	}
+}
+
+// Writes the -carve bytes at the match r (fewer at the end of the input) to
+// a new file, noting its name in the output, if carving.
+func carveMatch(input *input, r match) {
+	if !carving() {
+		return
+	}
+
+	f, err := createCarveFile()
+	if err != nil {
+		fileError(input.path, "carve", err, "%s: could not carve at offset %d; %s", input.path, r.Offset, err)
+		return
+	}
+	_, err = io.Copy(f, io.NewSectionReader(input.ra, int64(r.Offset), *carveSize))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fileError(input.path, "carve", err, "%s: could not carve at offset %d; %s", input.path, r.Offset, err)
+		return
+	}
+	fmt.Fprintf(input.out, "        carved to %s\n", f.Name())
+}
+
+// Creates the next numbered file in -carve-dir, skipping the numbers of
+// files already there.
+func createCarveFile() (*os.File, error) {
+	carveMutex.Lock()
+	defer carveMutex.Unlock()
+	for {
+		carveNumber++
+		name := filepath.Join(*carveDir, fmt.Sprintf("%06d.bin", carveNumber))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+This file implements sift's checkpoints: with -checkpoint, the path of
+each file is recorded in the checkpoint file once its results have been
+written, so that a run that is interrupted can be continued with -resume,
+which skips the files recorded. A file is the unit of progress; one
+interrupted part way through is searched again in full. The checkpoint
+file is removed once a run completes.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"myerr"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	checkpoint_header   = "sift checkpoint 1"
+	checkpoint_interval = time.Second // how often the record is written out
+)
+
+var (
+	checkpointMutex   sync.Mutex
+	checkpointFile    *os.File
+	checkpointWriter  *bufio.Writer
+	checkpointDone    chan bool       // closed to stop the periodic writing
+	checkpointWriting sync.WaitGroup  // done once the periodic writing has stopped
+	completed         map[string]bool // the files recorded by an earlier run, with -resume
+)
+
+// Opens the checkpoint file, if -checkpoint was given, first reading the
+// files it records if -resume was. Exits if it cannot be read or written.
+func openCheckpoint() {
+	if *checkpointPath == "" {
+		if *resume {
+			myerr.MyImmediateFatal(status_fatal_error, "error: -resume requires -checkpoint")
+		}
+		return
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if *resume {
+		var err error
+		if completed, err = readCheckpoint(*checkpointPath); err != nil {
+			myerr.MyImmediateFatal(status_fatal_error, "error: could not resume from %s; %s", *checkpointPath, err)
+		}
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(*checkpointPath, flags, 0644)
+	if err != nil {
+		myerr.MyImmediateFatal(status_fatal_error, "error: could not open %s; %s", *checkpointPath, err)
+	}
+	checkpointFile, checkpointWriter = f, bufio.NewWriter(f)
+	if !*resume {
+		fmt.Fprintln(checkpointWriter, checkpoint_header)
+	}
+
+	checkpointDone = make(chan bool)
+	checkpointWriting.Add(1)
+	go func() {
+		defer checkpointWriting.Done()
+		ticker := time.NewTicker(checkpoint_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushCheckpoint()
+			case <-checkpointDone:
+				return
+			}
+		}
+	}()
+}
+
+// Returns the files recorded in the checkpoint file at path.
+func readCheckpoint(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	files := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || scanner.Text() != checkpoint_header {
+		return nil, errors.New("not a sift checkpoint file")
+	}
+	for scanner.Scan() {
+		// the last line may be incomplete if the run was interrupted
+		if file, err := strconv.Unquote(scanner.Text()); err == nil {
+			files[file] = true
+		}
+	}
+	return files, scanner.Err()
+}
+
+// Was the file at path recorded by the run being resumed?
+func alreadyCompleted(path string) bool {
+	return completed[path]
+}
+
+// Records that the results for the file at path have been written.
+func noteCompleted(path string) {
+	checkpointMutex.Lock()
+	defer checkpointMutex.Unlock()
+	if checkpointWriter == nil {
+		return
+	}
+	fmt.Fprintln(checkpointWriter, strconv.Quote(path))
+}
+
+// Writes out what has been recorded so far.
+func flushCheckpoint() {
+	checkpointMutex.Lock()
+	defer checkpointMutex.Unlock()
+	if err := checkpointWriter.Flush(); err != nil {
+		myerr.MyImmediateFatal(status_fatal_error, "error: could not write %s; %s", *checkpointPath, err)
+	}
+}
+
+// Removes the checkpoint file, if any, once the run has completed.
+func closeCheckpoint() {
+	if checkpointFile == nil {
+		return
+	}
+	// a write under way must finish before the file is closed
+	close(checkpointDone)
+	checkpointWriting.Wait()
+	checkpointMutex.Lock()
+	checkpointFile.Close()
+	checkpointFile, checkpointWriter = nil, nil
+	checkpointMutex.Unlock()
+	if err := os.Remove(*checkpointPath); err != nil {
+		myerr.MyError("warning: could not remove %s; %s", *checkpointPath, err)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+This file includes tests for sift's checkpoints.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "checkpoint")
+	// the last line was cut short by an interruption
+	contents := checkpoint_header + "\n\"/data/a\"\n\"/data/with \\\"quotes\\\"\\n\"\n\"/data/cut"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := readCheckpoint(path)
+	if err != nil || len(files) != 2 || !files["/data/a"] || !files["/data/with \"quotes\"\n"] {
+		t.Error(fmt.Sprintf("unexpected files %v, %v", files, err))
+	}
+
+	ioutil.WriteFile(path, []byte("\"/data/a\"\n"), 0644)
+	if _, err = readCheckpoint(path); err == nil {
+		t.Error("expected an error for a file without the header")
+	}
+	if _, err = readCheckpoint(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	defer func(p string, r bool) {
+		*checkpointPath, *resume, completed = p, r, nil
+	}(*checkpointPath, *resume)
+	*checkpointPath = path
+
+	openCheckpoint()
+	noteCompleted("/data/a")
+	flushCheckpoint()
+	if files, err := readCheckpoint(path); err != nil || len(files) != 1 || !files["/data/a"] {
+		t.Error(fmt.Sprintf("unexpected files %v, %v", files, err))
+	}
+	checkpointDone <- true // stop the periodic writing, leaving the file as an interrupted run would
+
+	*resume = true
+	checkpointWriter.Flush()
+	checkpointFile.Close()
+	openCheckpoint()
+	if !alreadyCompleted("/data/a") || alreadyCompleted("/data/b") {
+		t.Error(fmt.Sprintf("unexpected files completed %v", completed))
+	}
+	noteCompleted("/data/b")
+	flushCheckpoint()
+	if files, err := readCheckpoint(path); err != nil || len(files) != 2 || !files["/data/b"] {
+		t.Error(fmt.Sprintf("unexpected files %v after resuming, %v", files, err))
+	}
+
+	closeCheckpoint()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error(fmt.Sprintf("expected the checkpoint file to be removed, got %v", err))
+	}
+	noteCompleted("/data/c") // once closed, nothing more is recorded
+}
@@ -0,0 +1,66 @@
+/*
+This file implements sift's colorized output (the -color flag).
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"myerr"
+	"os"
+)
+
+// ANSI terminal escapes
+const (
+	color_reset  = "\x1b[0m"
+	color_path   = "\x1b[35m"   // magenta
+	color_offset = "\x1b[32m"   // green
+	color_match  = "\x1b[1;31m" // bold red
+)
+
+// set by setupColor; if false, the color functions return their argument
+// unchanged
+var useColor bool
+
+// Decides whether output is colored from the -color flag, the NO_COLOR
+// environment variable (see no-color.org), and whether the output is a
+// terminal.
+func setupColor() {
+	switch *colorMode {
+	case "always":
+		useColor = true
+	case "never":
+		useColor = false
+	case "auto":
+		useColor = os.Getenv("NO_COLOR") == "" && *outputFile == "" && isTerminal(os.Stdout)
+	default:
+		myerr.MyImmediateFatal(status_fatal_error, "error: -color must be one of always, never, or auto; got \"%s\"", *colorMode)
+	}
+}
+
+// Is f a terminal (or some other character device)?
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func paint(color, s string) string {
+	if !useColor || s == "" {
+		return s
+	}
+	return color + s + color_reset
+}
+
+func colorPath(s string) string {
+	return paint(color_path, s)
+}
+
+func colorOffset(s string) string {
+	return paint(color_offset, s)
+}
+
+func colorMatch(s string) string {
+	return paint(color_match, s)
+}
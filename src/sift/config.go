@@ -0,0 +1,90 @@
+/*
+This file implements the reading of default flags for sift from its
+configuration file ($XDG_CONFIG_HOME/sift/config, or ~/.config/sift/config)
+and from the SIFT_OPTS environment variable. Each holds flags written as
+on the command line; in the file they may be spread over several lines,
+and a line beginning with # is a comment. The file's flags are applied
+first, then SIFT_OPTS's, then the command line's, so that later values of
+a flag override earlier ones, while the values of repeatable flags (e.g.,
+-exclude-dir) accumulate.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"myerr"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the path of the configuration file, or "" if there is no home
+// directory to find it in.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "sift", "config")
+}
+
+// Returns the flags held in the configuration file at path; a missing file
+// holds none.
+func readConfig(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			args = append(args, strings.Fields(line)...)
+		}
+	}
+	return args, scanner.Err()
+}
+
+// Applies the default flags from the configuration file and SIFT_OPTS.
+// Exits if they are not all valid flags.
+func parseDefaults() {
+	path := configPath()
+	var fileArgs []string
+	if path != "" {
+		var err error
+		if fileArgs, err = readConfig(path); err != nil {
+			myerr.MyImmediateFatal(status_fatal_error, "error: could not read %s; %s", path, err)
+		}
+	}
+	applyDefaults(path, fileArgs)
+	applyDefaults("SIFT_OPTS", strings.Fields(os.Getenv("SIFT_OPTS")))
+}
+
+// Parses args, which came from source, as flags.
+func applyDefaults(source string, args []string) {
+	if len(args) == 0 {
+		return
+	}
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	defer flag.CommandLine.Init(os.Args[0], flag.ExitOnError)
+	if err := flag.CommandLine.Parse(args); err != nil {
+		myerr.MyImmediateFatal(status_fatal_error, "error: in the defaults from %s; %s", source, err)
+	}
+	if flag.NArg() != 0 {
+		myerr.MyImmediateFatal(status_fatal_error, "error: in the defaults from %s; %q is not a flag", source, flag.Arg(0))
+	}
+}
@@ -0,0 +1,61 @@
+/*
+This file includes tests for sift's default flags.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	contents := "# defaults\n-hidden\n\n  -m 5   -exclude-dir .git\n\t# indented comment\n-color never\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := readConfig(path)
+	want := "-hidden -m 5 -exclude-dir .git -color never"
+	if err != nil || strings.Join(args, " ") != want {
+		t.Error(fmt.Sprintf("expected %q, got %q, %v", want, strings.Join(args, " "), err))
+	}
+
+	if args, err = readConfig(filepath.Join(dir, "missing")); err != nil || args != nil {
+		t.Error(fmt.Sprintf("expected no flags and no error for a missing file, got %q, %v", args, err))
+	}
+	if _, err = readConfig(dir); err == nil {
+		t.Error("expected an error for a directory")
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	defer func(hidden bool, count int64) {
+		*includeHidden, *maxCount = hidden, count
+	}(*includeHidden, *maxCount)
+
+	applyDefaults("config", []string{"-hidden", "-m", "5"})
+	if !*includeHidden || *maxCount != 5 {
+		t.Error(fmt.Sprintf("expected -hidden and -m 5, got %v and %d", *includeHidden, *maxCount))
+	}
+
+	// a later source overrides an earlier one, and leaves the rest alone
+	applyDefaults("SIFT_OPTS", []string{"-m", "7"})
+	if !*includeHidden || *maxCount != 7 {
+		t.Error(fmt.Sprintf("expected -hidden and -m 7, got %v and %d", *includeHidden, *maxCount))
+	}
+
+	applyDefaults("SIFT_OPTS", nil)
+	if *maxCount != 7 {
+		t.Error(fmt.Sprintf("expected no defaults to leave -m 7, got %d", *maxCount))
+	}
+}
@@ -0,0 +1,149 @@
+/*
+This file implements the display of the bytes surrounding a match (the
+-A, -B, -C, -hex, and -hexdump flags, and the %c placeholder of -format).
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hexdump"
+	"strconv"
+)
+
+// how many bytes either side of a match the %c placeholder shows when
+// none of -A, -B, or -C is given
+const default_context = 16
+
+// how many bytes either side of a match -hexdump shows when none of -A, -B,
+// or -C is given
+const default_dump_context = 32
+
+// Returns how many bytes of context to show before and after a match. -B
+// and -A take precedence over -C; if none of them is given, fallback is
+// used for both.
+func contextWidths(fallback int) (before, after int) {
+	if *contextBefore < 0 && *contextAfter < 0 && *contextBoth < 0 {
+		return fallback, fallback
+	}
+	before, after = *contextBoth, *contextBoth
+	if before < 0 {
+		before, after = 0, 0
+	}
+	if *contextBefore >= 0 {
+		before = *contextBefore
+	}
+	if *contextAfter >= 0 {
+		after = *contextAfter
+	}
+	return
+}
+
+// Was any of -A, -B, or -C given?
+func wantContext() bool {
+	before, after := contextWidths(0)
+	return before > 0 || after > 0
+}
+
+// Returns the match r within input and the bytes around it, as
+// escaped text or, with -hex, as hex bytes, with the match colored. If
+// color is off, a hex match is set off by brackets.
+func renderContext(input *input, r match, before, after int) string {
+	data, start := matchContext(input, r, before, after)
+	end := start + len(r.data)
+	if !*contextHex {
+		return escapeBytes(data[:start]) + colorMatch(escapeBytes(data[start:end])) + escapeBytes(data[end:])
+	}
+
+	match := hexBytes(data[start:end])
+	if useColor {
+		match = colorMatch(match)
+	} else {
+		match = "[" + match + "]"
+	}
+	parts := make([]string, 0, 3)
+	if start > 0 {
+		parts = append(parts, hexBytes(data[:start]))
+	}
+	parts = append(parts, match)
+	if end < len(data) {
+		parts = append(parts, hexBytes(data[end:]))
+	}
+
+	var buf bytes.Buffer
+	for i, part := range parts {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(part)
+	}
+	return buf.String()
+}
+
+// Returns the match r within input along with up to before bytes
+// preceding it and after bytes following it, and the index of the match
+// within them. If the input does not allow random access (e.g., the
+// standard input), only the match itself is returned.
+func matchContext(input *input, r match, before, after int) (data []byte, matchStart int) {
+	if input.ra == nil {
+		return r.data, 0
+	}
+	offset := int64(r.Offset)
+	data, start, err := hexdump.ReadWindow(input.ra, input.size, offset, len(r.data), before, after)
+	if err != nil || len(data) < int(offset-start)+len(r.data) {
+		return r.data, 0
+	}
+	return data, int(offset - start)
+}
+
+// Returns b as text with non-printable bytes, quotes, and backslashes
+// escaped as in a Go string literal, but without the surrounding quotes.
+func escapeBytes(b []byte) string {
+	quoted := strconv.Quote(string(b))
+	return quoted[1 : len(quoted)-1]
+}
+
+// Returns b as space-separated pairs of hex digits.
+func hexBytes(b []byte) string {
+	var buf bytes.Buffer
+	for i, c := range b {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%02x", c)
+	}
+	return buf.String()
+}
+
+// Returns ": " followed by the context of the match r if -A, -B,
+// or -C was given, otherwise the empty string.
+func contextSuffix(input *input, r match) string {
+	if !wantContext() {
+		return ""
+	}
+	before, after := contextWidths(0)
+	return ": " + renderContext(input, r, before, after)
+}
+
+// Writes an xxd-style dump of the bytes around the match r, with
+// the match highlighted, if -hexdump was given.
+func dumpMatch(input *input, r match) {
+	if !*hexDump {
+		return
+	}
+
+	opts := &hexdump.Options{}
+	if useColor {
+		opts.HighlightOn, opts.HighlightOff = color_match, color_reset
+	}
+	before, after := contextWidths(default_dump_context)
+	data, start := matchContext(input, r, before, after)
+	err := hexdump.Dump(input.out, data, r.Offset-uint64(start), start, len(r.data), opts)
+	if err != nil {
+		searchError(input.path, err)
+	}
+}
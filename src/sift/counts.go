@@ -0,0 +1,73 @@
+/*
+This file implements sift's aggregated counts: -count-total, which reports
+one grand total of the matches in place of a count per input, and
+-count-dirs, which reports the total of the files directly within each
+directory.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	countsMutex sync.Mutex
+	grandTotal  uint64
+	dirTotals   map[string]uint64
+)
+
+// Are the counts of -c aggregated rather than reported for each input?
+func aggregatingCounts() bool {
+	return *countTotal || *countDirs
+}
+
+// Empties the aggregated counts, before a search of the inputs.
+func resetCounts() {
+	grandTotal, dirTotals = 0, map[string]uint64{}
+}
+
+// Adds the count for the input at path to the aggregated counts.
+func addCount(path string, count uint64) {
+	countsMutex.Lock()
+	defer countsMutex.Unlock()
+
+	grandTotal += count
+	if count > 0 {
+		// a file within an archive counts toward the archive's directory
+		if bang := strings.Index(path, "!"); bang >= 0 {
+			path = path[:bang]
+		}
+		dirTotals[filepath.Dir(path)] += count
+	}
+}
+
+// Writes the aggregated counts to output: each directory's with
+// -count-dirs, then the grand total. The total is written alone, as a bare
+// number, with -count-total only.
+func printCounts() {
+	if !aggregatingCounts() {
+		return
+	}
+
+	if *countDirs {
+		dirs := make([]string, 0, len(dirTotals))
+		for dir := range dirTotals {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			fmt.Fprintf(output, "%s: %d%s", colorPath(dir), dirTotals[dir], recordEnd())
+		}
+		fmt.Fprintf(output, "total: %d%s", grandTotal, recordEnd())
+	} else {
+		fmt.Fprintf(output, "%d%s", grandTotal, recordEnd())
+	}
+}
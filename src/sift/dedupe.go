@@ -0,0 +1,119 @@
+/*
+This file implements sift's -dedupe, which searches only one file of each
+set with the same content, reporting the others after the results as
+having the same content as the one searched, if it was reported. Files
+are taken to have the same content when they are of the same size and
+the same bytes are found at their start, middle, and end; this is much
+cheaper than reading them in full, but files that differ only elsewhere
+are taken as the same.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// how many bytes are hashed at each of the start, middle, and end of a
+// file to identify its content
+const dedupe_sample_size = 64 * 1024
+
+// What identifies a file's content: its size and the hash of its samples.
+type contentKey struct {
+	size int64
+	hash [sha256.Size]byte
+}
+
+var (
+	representatives map[contentKey]string // the file searched for each content
+	duplicates      map[string][]string   // the other files with each searched one's content
+	reportedMutex   sync.Mutex
+	reported        map[string]bool // the inputs for which something was reported
+)
+
+// Empties the record of contents seen, before a search of the inputs.
+func resetDedupe() {
+	representatives = map[contentKey]string{}
+	duplicates = map[string][]string{}
+	reported = map[string]bool{}
+}
+
+// Notes that something was reported for the input at path, if that is of
+// interest.
+func noteReported(path string) {
+	if !*dedupe {
+		return
+	}
+	reportedMutex.Lock()
+	reported[path] = true
+	reportedMutex.Unlock()
+}
+
+// Should the file at path, of size bytes, be searched? Not with -dedupe if
+// a file with the same content already was. A file that cannot be read is
+// searched, so that the error is reported.
+func firstOfContent(path string, size int64) bool {
+	if !*dedupe {
+		return true
+	}
+	key, err := contentOf(path, size)
+	if err != nil {
+		return true
+	}
+	if first, seen := representatives[key]; seen {
+		duplicates[first] = append(duplicates[first], path)
+		return false
+	}
+	representatives[key] = path
+	return true
+}
+
+// Returns what identifies the content of the file at path, of size bytes.
+func contentOf(path string, size int64) (contentKey, error) {
+	key := contentKey{size: size}
+	f, err := openFile(path)
+	if err != nil {
+		return key, err
+	}
+	defer closeFile(f)
+
+	h := sha256.New()
+	if size <= 3*dedupe_sample_size {
+		_, err = io.Copy(h, f)
+	} else {
+		for _, offset := range []int64{0, size/2 - dedupe_sample_size/2, size - dedupe_sample_size} {
+			if _, err = io.Copy(h, io.NewSectionReader(f, offset, dedupe_sample_size)); err != nil {
+				break
+			}
+		}
+	}
+	h.Sum(key.hash[:0])
+	return key, err
+}
+
+// Writes each duplicate of a file for which something was reported to
+// output.
+func printDuplicates() {
+	if !*dedupe {
+		return
+	}
+	firsts := make([]string, 0, len(duplicates))
+	for first := range duplicates {
+		if reported[first] {
+			firsts = append(firsts, first)
+		}
+	}
+	sort.Strings(firsts)
+	for _, first := range firsts {
+		for _, path := range duplicates[first] {
+			fmt.Fprintf(output, "%s: same content as %s%s", colorPath(path), first, recordEnd())
+		}
+	}
+}
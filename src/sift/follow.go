@@ -0,0 +1,86 @@
+/*
+This file implements sift's follow mode (the -follow flag). Once the inputs
+have been searched, they are checked again periodically: data appended to
+a file is searched, as are files that appear in the directories searched.
+Changes are found by polling, so no notification facility is required.
+
+Only the appended data of a plain file is searched; a file that shrinks
+(e.g., is truncated or replaced) is searched again in full, as is a
+compressed file or an archive that changes.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	followed      = map[string]int64{} // the size of each file when last searched
+	followedMutex sync.Mutex
+)
+
+// Returns the offset from which the file at path, now of size bytes, is to
+// be searched, and whether it needs to be searched at all. reported is the
+// size it had when last searched: the matches ending there or before were
+// reported then, and are not to be again. Outside of follow mode every
+// file is searched in full.
+func followFrom(path string, size int64) (from, reported int64, search bool) {
+	if !*followMode {
+		return 0, 0, true
+	}
+
+	followedMutex.Lock()
+	defer followedMutex.Unlock()
+
+	previous, seen := followed[path]
+	followed[path] = size
+	if !seen || size < previous {
+		return 0, 0, true
+	} else if size == previous {
+		return 0, 0, false
+	} else if *decompressInputs || (*searchArchives && archiveKind(path) != archive_none) {
+		return 0, 0, true
+	}
+
+	// back up so that a match straddling the old end is found
+	from = previous - int64(followOverlap())
+	if from < 0 {
+		from = 0
+	}
+	return from, previous, true
+}
+
+// Returns how far before the previous end of a followed file the search of
+// its appended data begins, so that matches straddling it are found. A
+// regular expression's matches are of unknown length, so only those
+// beginning after the previous end are found.
+func followOverlap() int {
+	if expression != nil {
+		return 0
+	}
+	longest := 0
+	for _, p := range patterns {
+		if p.needle.Len() > longest {
+			longest = p.needle.Len()
+		}
+	}
+	return longest - 1
+}
+
+// Searches the inputs again every -follow-interval, returning only once
+// the -deadline, if any, passes.
+func followInputs(inputs []string) {
+	*processStdin = false // the standard input was read through
+	for {
+		time.Sleep(*followInterval)
+		if deadlinePassed() {
+			return
+		}
+		searchInputs(inputs, "", false)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+This file implements sift's user-definable output template (the -format
+flag).
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Expands the placeholders of format for the number'th match, r, within
+// input. Unknown placeholders are left as they are.
+func expandFormat(format string, input *input, r match, number int) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			buf.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch format[i] {
+		case 'p':
+			buf.WriteString(colorPath(input.path))
+		case 'o':
+			buf.WriteString(colorOffset(strconv.FormatUint(r.Offset, 10)))
+		case 'r':
+			buf.WriteString(colorOffset(formatOffset(r.Offset)))
+		case 'O':
+			buf.WriteString(colorOffset("0x" + strconv.FormatUint(r.Offset, 16)))
+		case 't':
+			buf.WriteString(patterns[r.Pattern].label)
+		case 'n':
+			buf.WriteString(strconv.Itoa(number))
+		case 'c':
+			before, after := contextWidths(default_context)
+			buf.WriteString(renderContext(input, r, before, after))
+		case '%':
+			buf.WriteByte('%')
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(format[i])
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,157 @@
+/*
+This file implements ignore files: during recursive descent, the
+.gitignore and .siftignore files of each directory name paths beneath it
+that are not searched, in the manner of git. The -no-ignore flag turns
+this off.
+
+Supported are blank lines and # comments, ! to re-include, a trailing / to
+match directories only, a leading or inner / to anchor a pattern to the
+directory of the ignore file, the wildcards of path/filepath.Match, and **
+to match any number of directories.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"path/filepath"
+	"strings"
+)
+
+// the ignore files read in each directory, in increasing precedence
+var ignoreFileNames = []string{".gitignore", ".siftignore"}
+
+// One line of an ignore file.
+type ignoreRule struct {
+	segments []string // the pattern split at slashes
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// The rules of the ignore files in one directory, along with those of the
+// directories above it.
+type ignoreList struct {
+	parent *ignoreList
+	base   string // the directory holding the ignore files
+	rules  []ignoreRule
+}
+
+// Returns the rules that apply beneath the directory dir: those of parent
+// plus those of dir's ignore files. If dir has no ignore files, parent
+// itself is returned.
+func readIgnores(parent *ignoreList, dir string) *ignoreList {
+	if *noIgnore {
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, name := range ignoreFileNames {
+		f, err := openFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+		closeFile(f)
+	}
+
+	if len(rules) == 0 {
+		return parent
+	}
+	return &ignoreList{parent, dir, rules}
+}
+
+// Parses one line of an ignore file. Returns ok=false for blank lines and
+// comments.
+func parseIgnoreRule(line string) (rule ignoreRule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return
+	}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// Is the entry at path (a directory if isDir) ignored? The nearest ignore
+// file takes precedence, and within a file the last matching rule.
+func (l *ignoreList) ignored(path string, isDir bool) bool {
+	for ; l != nil; l = l.parent {
+		rel, err := filepath.Rel(l.base, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for i := len(l.rules) - 1; i >= 0; i-- {
+			if l.rules[i].matches(segments, isDir) {
+				return !l.rules[i].negate
+			}
+		}
+	}
+	return false
+}
+
+// Does the rule match the path made up of segments (relative to the
+// directory of the rule's ignore file)?
+func (r *ignoreRule) matches(segments []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		matched, _ := filepath.Match(r.segments[0], segments[len(segments)-1])
+		return matched
+	}
+	return matchSegments(r.segments, segments)
+}
+
+// Does the pattern made up of patterns match the path made up of
+// segments? A ** pattern matches any number of segments, except that a
+// final ** matches at least one (so dir/** matches what is within dir, but
+// not dir itself).
+func matchSegments(patterns, segments []string) bool {
+	if len(patterns) == 0 {
+		return len(segments) == 0
+	}
+	if len(patterns) == 1 && patterns[0] == "**" {
+		return len(segments) > 0
+	}
+	if patterns[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(patterns[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := filepath.Match(patterns[0], segments[0]); !matched {
+		return false
+	}
+	return matchSegments(patterns[1:], segments[1:])
+}
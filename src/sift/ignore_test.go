@@ -0,0 +1,159 @@
+/*
+This file includes tests for sift's ignore files.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	cases := []struct {
+		line     string
+		ok       bool
+		segments string
+		negate   bool
+		dirOnly  bool
+		anchored bool
+	}{
+		{"", false, "", false, false, false},
+		{"# a comment", false, "", false, false, false},
+		{"/", false, "", false, false, false},
+		{"*.log", true, "*.log", false, false, false},
+		{"*.log \t\r", true, "*.log", false, false, false},
+		{"!keep.log", true, "keep.log", true, false, false},
+		{"\\!important", true, "!important", false, false, false},
+		{"\\#hash", true, "#hash", false, false, false},
+		{"build/", true, "build", false, true, false},
+		{"/root.txt", true, "root.txt", false, false, true},
+		{"docs/**/*.md", true, "docs/**/*.md", false, false, true},
+		{"!/out//", true, "out", true, true, true},
+	}
+	for _, c := range cases {
+		rule, ok := parseIgnoreRule(c.line)
+		if ok != c.ok {
+			t.Error(fmt.Sprintf("expected ok=%v for %q, got %v", c.ok, c.line, ok))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if segments := strings.Join(rule.segments, "/"); segments != c.segments || rule.negate != c.negate || rule.dirOnly != c.dirOnly || rule.anchored != c.anchored {
+			t.Error(fmt.Sprintf("%q gave %q negate=%v dirOnly=%v anchored=%v; expected %q negate=%v dirOnly=%v anchored=%v",
+				c.line, segments, rule.negate, rule.dirOnly, rule.anchored, c.segments, c.negate, c.dirOnly, c.anchored))
+		}
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/b/c", false},
+		{"a/*", "a/b", true},
+		{"a/*", "a/b/c", false},
+		{"a/*.go", "a/x.go", true},
+		{"a/*.go", "b/x.go", false},
+		{"a/**", "a", false},
+		{"a/**", "a/b", true},
+		{"a/**", "a/b/c", true},
+		{"**/x", "x", true},
+		{"**/x", "a/b/x", true},
+		{"**/x", "a/x/b", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/c", false},
+		{"**", "a/b", true},
+	}
+	for _, c := range cases {
+		if got := matchSegments(strings.Split(c.pattern, "/"), strings.Split(c.path, "/")); got != c.want {
+			t.Error(fmt.Sprintf("matchSegments(%q, %q) = %v; expected %v", c.pattern, c.path, got, c.want))
+		}
+	}
+}
+
+// Returns the ignore list for the directory base holding the rules in
+// lines, beneath parent.
+func ignoreListOf(parent *ignoreList, base string, lines ...string) *ignoreList {
+	l := &ignoreList{parent, base, nil}
+	for _, line := range lines {
+		if rule, ok := parseIgnoreRule(line); ok {
+			l.rules = append(l.rules, rule)
+		}
+	}
+	return l
+}
+
+func TestIgnored(t *testing.T) {
+	top := filepath.FromSlash("/top")
+	rules := ignoreListOf(nil, top, "*.log", "!keep.log", "build/", "/only-root.txt", "docs/**/*.md")
+	rules = ignoreListOf(rules, filepath.Join(top, "sub"), "!*.log")
+
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"keep.log", false, false},
+		{"deep/b.log", false, true},
+		{"deep/keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"deep/build", true, true},
+		{"only-root.txt", false, true},
+		{"deep/only-root.txt", false, false},
+		{"docs/a/b.md", false, true},
+		{"docs/b.md", false, true},
+		{"other/docs/b.md", false, false},
+		{"docs.md", false, false},
+		{"sub/x.log", false, false},
+		{"sub/build", true, true},
+		{"../elsewhere/a.log", false, false},
+	}
+	for _, c := range cases {
+		path := filepath.Join(top, filepath.FromSlash(c.path))
+		if got := rules.ignored(path, c.isDir); got != c.want {
+			t.Error(fmt.Sprintf("ignored(%q, %v) = %v; expected %v", c.path, c.isDir, got, c.want))
+		}
+	}
+	if (*ignoreList)(nil).ignored(filepath.Join(top, "a.log"), false) {
+		t.Error("expected nothing to be ignored without rules")
+	}
+}
+
+func TestReadIgnores(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# logs\n*.log\n\ntmp/\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".siftignore"), []byte("!keep.log\n"), 0644)
+
+	rules := readIgnores(nil, dir)
+	if rules == nil || len(rules.rules) != 3 {
+		t.Fatal(fmt.Sprintf("expected 3 rules, got %v", rules))
+	}
+	if !rules.ignored(filepath.Join(dir, "a.log"), false) || rules.ignored(filepath.Join(dir, "keep.log"), false) {
+		t.Error("expected .siftignore to take precedence over .gitignore")
+	}
+
+	// a directory without ignore files shares its parent's rules
+	if sub := readIgnores(rules, filepath.Join(dir, "missing")); sub != rules {
+		t.Error("expected the parent's rules for a directory without ignore files")
+	}
+
+	*noIgnore = true
+	defer func() { *noIgnore = false }()
+	if readIgnores(nil, dir) != nil {
+		t.Error("expected no rules with -no-ignore")
+	}
+}
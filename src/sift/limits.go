@@ -0,0 +1,51 @@
+/*
+This file implements sift's match limits: -m, which ends the search of a
+file after a number of matches, and -max-count-total, which ends the whole
+run.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"substr"
+	"sync/atomic"
+)
+
+// the matches reported so far, across all inputs; updated atomically, as
+// workers share it
+var totalMatches int64
+
+// Counts a match against -max-count-total. Returns false, meaning the
+// match is not to be reported, if the limit has already been reached.
+func countMatch() bool {
+	if *maxCountTotal <= 0 {
+		return true
+	}
+	return atomic.AddInt64(&totalMatches, 1) <= *maxCountTotal
+}
+
+// Has -max-count-total been reached? If so, no further inputs are searched.
+func limitReached() bool {
+	return *maxCountTotal > 0 && atomic.LoadInt64(&totalMatches) >= *maxCountTotal
+}
+
+// Has the number of matches reported for one input reached -m?
+func fileLimitReached(count int64) bool {
+	return *maxCount > 0 && count >= *maxCount
+}
+
+// Returns the options for searching input, which end the search once done
+// is closed and include -m's limit where the library can apply it: not
+// when only some of the matches it finds are kept (by -record-whole, or by
+// -follow when part of the input was already searched).
+func searchOptions(input *input, done <-chan struct{}) []substr.Option {
+	opts := append([]substr.Option(nil), input.opts...)
+	opts = append(opts, substr.WithCancel(done))
+	if *maxCount > 0 && !filteringRecords() && input.reported == 0 {
+		opts = append(opts, substr.WithLimit(uint64(*maxCount)))
+	}
+	return opts
+}
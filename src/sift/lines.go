@@ -0,0 +1,152 @@
+/*
+This file implements sift's grep-style line output (the -lines flag).
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"substr"
+)
+
+//// TYPE stoppableReader ////
+
+// A reader that ends, as though its data did, once done is closed, so that
+// a search reading from it can be stopped.
+type stoppableReader struct {
+	r    io.Reader
+	done <-chan struct{}
+}
+
+func (s stoppableReader) Read(p []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, io.EOF
+	default:
+		return s.r.Read(p)
+	}
+}
+
+//// FUNCTIONS ////
+
+// Prints each line of input containing a match as path:line:content.
+// Returns how many lines were printed.
+func processLines(input *input) int {
+	r, err := input.haystack.Reader()
+	if err != nil {
+		searchError(input.path, err)
+		return 0
+	}
+
+	// once a limit is reached the reading stops, and what was already
+	// found is passed over
+	done := make(chan struct{})
+	r = stoppableReader{r, done}
+	var lines <-chan substr.LineMatch
+	if expression != nil {
+		lines = regexpLines(r)
+	} else {
+		lines = substr.LineMatchesSet(r, needles)
+	}
+
+	count := 0
+	stopped := false
+	for match := range lines {
+		if match.Error != nil {
+			searchError(input.path, match.Error)
+			continue
+		}
+		if stopped {
+			continue
+		}
+		if fileLimitReached(int64(count)) || !countMatch() {
+			stopped = true
+			close(done)
+			continue
+		}
+		count++
+		fmt.Fprintf(input.out, "%s:%s:%s%s",
+			colorPath(input.path),
+			colorOffset(strconv.FormatUint(match.LineNumber, 10)),
+			highlightMatches(match.Line),
+			recordEnd())
+	}
+	return count
+}
+
+// Searches r for lines matching expression, in the manner of
+// substr.LineMatches.
+func regexpLines(r io.Reader) <-chan substr.LineMatch {
+	out := make(chan substr.LineMatch, 32)
+	go func() {
+		defer close(out)
+
+		in := bufio.NewReader(r)
+		lineNumber := uint64(0)
+		lineStart := uint64(0)
+		for {
+			line, err := in.ReadBytes('\n')
+			if len(line) > 0 {
+				lineNumber++
+				content := bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+				if loc := expression.FindIndex(content); loc != nil {
+					out <- substr.LineMatch{
+						LineNumber: lineNumber,
+						Offset:     lineStart + uint64(loc[0]),
+						LineStart:  lineStart,
+						Line:       content}
+				}
+				lineStart += uint64(len(line))
+			}
+
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				out <- substr.LineMatch{Error: err}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Returns line with every match of the needles colored. If color is off,
+// line is returned as is.
+func highlightMatches(line []byte) string {
+	if !useColor {
+		return string(line)
+	}
+
+	var buf bytes.Buffer
+	start := 0
+	if expression != nil {
+		for _, loc := range expression.FindAllIndex(line, -1) {
+			buf.Write(line[start:loc[0]])
+			buf.WriteString(colorMatch(string(line[loc[0]:loc[1]])))
+			start = loc[1]
+		}
+	} else {
+		for result := range substr.IndexesSet(substr.NewHaystackBytes(line), needles) {
+			if result.Error != nil {
+				break
+			}
+			offset := int(result.Offset)
+			if offset < start {
+				continue // overlaps the previous match
+			}
+			buf.Write(line[start:offset])
+			end := offset + patterns[result.Pattern].needle.Len()
+			buf.WriteString(colorMatch(string(line[offset:end])))
+			start = end
+		}
+	}
+	buf.Write(line[start:])
+	return buf.String()
+}
@@ -0,0 +1,25 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+/*
+This file stands in for memory mapping on systems where sift does not
+support it; files are then read as usual.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("memory mapping is not supported on this system")
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
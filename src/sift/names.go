@@ -0,0 +1,60 @@
+/*
+This file implements the matching of sift's needles, or its regular
+expression, against the names of the files and directories it visits
+(-names and -names-also).
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bytes"
+	"os"
+)
+
+// in follow mode, the paths whose names have been reported, so that each is
+// only reported once
+var namesReported = map[string]bool{}
+
+// Should the names of files and directories be matched?
+func matchingNames() bool {
+	return *namesOnly || *namesAlso
+}
+
+// Should the contents of files be searched?
+func searchingContents() bool {
+	return !*namesOnly
+}
+
+// Does name contain a needle or match the regular expression?
+func nameMatches(name string) bool {
+	if expression != nil {
+		return expression.MatchString(name)
+	}
+	for _, p := range patterns {
+		if bytes.Contains([]byte(name), p.needle.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports the file or directory at path if its name matches.
+func matchName(name, path string) {
+	if !matchingNames() || !nameMatches(name) {
+		return
+	}
+	if *followMode {
+		if namesReported[path] {
+			return
+		}
+		namesReported[path] = true
+	}
+	if *quiet {
+		os.Exit(status_found)
+	}
+	noteFound(path)
+	writeInOrder(colorPath(path) + recordEnd())
+}
@@ -0,0 +1,309 @@
+/*
+This file implements the needles sift searches for: each -t and -b flag
+adds one, and all are searched for in a single pass over each input.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	ba "bytearray"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"substr"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A needle given on the command line along with how it is identified in
+// output. In regular expression mode there is one pattern, with no needle.
+type pattern struct {
+	label  string
+	needle *substr.Needle
+	text   bool // given as text, and so subject to -all-encodings
+}
+
+// the needles given by -t and -b, in the order given
+var patterns []pattern
+
+// the same needles, as searched for
+var needles *substr.NeedleSet
+
+// A match found within an input: the library's Result along with the
+// bytes matched, which vary in length in regular expression mode.
+type match struct {
+	substr.Result
+	data []byte
+}
+
+//// TYPE textFlag ////
+
+// A flag.Value that adds a text needle each time the flag is given. If
+// escapes is true, the text may contain the escapes understood by
+// bytearray.ParseEscaped.
+type textFlag struct {
+	escapes bool
+}
+
+func (textFlag) String() string {
+	return ""
+}
+
+func (f textFlag) Set(value string) error {
+	text := []byte(value)
+	if f.escapes {
+		var err error
+		if text, err = ba.ParseEscaped(value); err != nil {
+			return err
+		}
+	}
+	patterns = append(patterns, pattern{value, substr.NewNeedleBytes(text), true})
+	return nil
+}
+
+//// TYPE bytesFlag ////
+
+// A flag.Value that adds a hex byte needle each time the flag is given.
+type bytesFlag struct{}
+
+func (bytesFlag) String() string {
+	return ""
+}
+
+func (bytesFlag) Set(value string) error {
+	var b ba.ByteArray
+	if err := (ba.FlagBytes{Target: &b}).Set(value); err != nil {
+		return err
+	}
+	patterns = append(patterns, pattern{"0x" + b.String(), substr.NewNeedleBytes(b), false})
+	return nil
+}
+
+//// TYPE fileFlag ////
+
+// A flag.Value that adds a needle read from the named file each time the
+// flag is given. A text needle is read without a final end of line, which
+// editors tend to add; a binary one is read as is.
+type fileFlag struct {
+	text bool
+}
+
+func (fileFlag) String() string {
+	return ""
+}
+
+func (f fileFlag) Set(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if f.text {
+		data = bytes.TrimSuffix(data, []byte("\n"))
+		data = bytes.TrimSuffix(data, []byte("\r"))
+	}
+	patterns = append(patterns, pattern{name, substr.NewNeedleBytes(data), f.text})
+	return nil
+}
+
+//// FUNCTIONS ////
+
+// Adds the UTF-16LE and UTF-16BE forms of each text needle, as needles in
+// their own right, for -all-encodings.
+func addEncodings() {
+	for _, p := range patterns {
+		if !p.text {
+			continue
+		}
+		wide := utf16.Encode(decodeText(p.needle.Bytes()))
+		le := make([]byte, 0, 2*len(wide))
+		be := make([]byte, 0, 2*len(wide))
+		for _, unit := range wide {
+			le = append(le, byte(unit), byte(unit>>8))
+			be = append(be, byte(unit>>8), byte(unit))
+		}
+		patterns = append(patterns,
+			pattern{p.label + " (UTF-16LE)", substr.NewNeedleBytes(le), false},
+			pattern{p.label + " (UTF-16BE)", substr.NewNeedleBytes(be), false})
+	}
+}
+
+// Returns the characters of text, which is normally UTF-8; a byte that is
+// not part of a valid UTF-8 sequence is taken as the character of the same
+// value, as in Latin-1.
+func decodeText(text []byte) []rune {
+	runes := make([]rune, 0, len(text))
+	for len(text) > 0 {
+		r, size := utf8.DecodeRune(text)
+		if r == utf8.RuneError && size <= 1 {
+			r, size = rune(text[0]), 1
+		}
+		runes = append(runes, r)
+		text = text[size:]
+	}
+	return runes
+}
+
+// Returns the match for a result of searching for the needles.
+func needleMatch(r substr.Result) match {
+	if r.Error != nil {
+		return match{r, nil}
+	}
+	return match{r, patterns[r.Pattern].needle.Bytes()}
+}
+
+// Searches input for the needles or the regular expression, sending each
+// match on the returned channel in offset order, up to the limits of -m and
+// -max-count-total.
+func findMatches(input *input) <-chan match {
+	out := make(chan match, 32)
+	go func() {
+		defer close(out)
+		count := int64(0)
+		if expression == nil {
+			// once a limit is reached the search is cancelled, and what it
+			// had already found is passed over
+			done := make(chan struct{})
+			stopped := false
+			for r := range substr.IndexesSet(input.haystack, needles, searchOptions(input, done)...) {
+				m := needleMatch(r)
+				if r.Error == nil && stopped {
+					continue
+				}
+				if r.Error == nil && m.Offset+uint64(len(m.data)) <= input.reported {
+					continue // found when the file was last searched
+				}
+				if r.Error == nil && !withinRecord(m.Offset, len(m.data)) {
+					continue
+				}
+				if r.Error == nil && (fileLimitReached(count) || !countMatch()) {
+					stopped = true
+					close(done)
+					continue
+				}
+				if r.Error == nil {
+					count++
+				}
+				out <- m
+			}
+			return
+		}
+
+		r, err := input.haystack.Reader()
+		if err == nil {
+			err = searchRegexp(r, func(m match) bool {
+				if !withinRecord(m.Offset+input.base, len(m.data)) {
+					return true
+				}
+				if !countMatch() {
+					return false
+				}
+				m.Offset += input.base
+				out <- m
+				count++
+				return !fileLimitReached(count)
+			})
+		}
+		if err != nil {
+			out <- match{substr.Result{Error: err}, nil}
+		}
+	}()
+	return out
+}
+
+// Searches input for the needles or the regular expression. Returns
+// found=true and the first match if there is one and -max-count-total has
+// not been reached.
+func findFirstMatch(input *input) (found bool, first match, e error) {
+	if filteringRecords() || input.reported > 0 {
+		// the first match may not be the first kept (e.g., one already
+		// reported by -follow); the rest are seen through
+		for m := range findMatches(input) {
+			if m.Error != nil {
+				e = m.Error
+			} else if !found {
+				found, first = true, m
+			}
+		}
+		return
+	}
+	if expression == nil {
+		var r substr.Result
+		found, r, e = substr.IndexSet(input.haystack, needles, input.opts...)
+		if found {
+			first = needleMatch(r)
+		}
+	} else {
+		var r io.Reader
+		if r, e = input.haystack.Reader(); e != nil {
+			return
+		}
+		e = searchRegexp(r, func(m match) bool {
+			m.Offset += input.base
+			found, first = true, m
+			return false
+		})
+	}
+	if found && !countMatch() {
+		found = false
+	}
+	return
+}
+
+// Returns " [label]" identifying the pattern matched by m if more than one
+// was given, otherwise the empty string.
+func patternTag(m match) string {
+	if len(patterns) < 2 {
+		return ""
+	}
+	return " [" + patterns[m.Pattern].label + "]"
+}
+
+// Reads input into memory if it does not allow random access (e.g., it is
+// the standard input), so that it can be searched more than once.
+func slurp(input *input) error {
+	if input.ra != nil {
+		return nil
+	}
+	r, err := input.haystack.Reader()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	input.haystack = substr.NewHaystackBytes(data)
+	input.ra = bytes.NewReader(data)
+	input.size = int64(len(data))
+	return nil
+}
+
+// Does input match? That is, does any needle occur within it or, with
+// -all, every needle? A stream (e.g., the standard input) is read into
+// memory first when every needle must be checked, since it must be
+// searched again to produce output.
+func inputMatches(input *input) (bool, error) {
+	if !*requireAll || len(patterns) < 2 {
+		found, _, err := findFirstMatch(input)
+		return found, err
+	}
+	if err := slurp(input); err != nil {
+		return false, err
+	}
+
+	// -m limits the matches reported, not those that show each needle
+	// occurs
+	coverage, err := substr.Coverage(input.haystack, needles, input.opts...)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range coverage {
+		if !c.Matched() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+/*
+This file includes tests for sift's needles and the matching of inputs.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"substr"
+	"testing"
+)
+
+// Sets the needles searched for to texts, restoring the earlier ones when
+// the test ends.
+func setNeedles(t *testing.T, texts ...string) {
+	savedPatterns, savedNeedles := patterns, needles
+	t.Cleanup(func() { patterns, needles = savedPatterns, savedNeedles })
+
+	patterns = nil
+	var set []*substr.Needle
+	for _, text := range texts {
+		needle := substr.NewNeedleStr(text)
+		patterns = append(patterns, pattern{text, needle, true})
+		set = append(set, needle)
+	}
+	needles = substr.NewNeedleSet(set...)
+}
+
+// Returns an input of data, as from a file.
+func inputOf(data string) *input {
+	return &input{"data", substr.NewHaystackStr(data), nil, int64(len(data)), nil, nil, 0, 0}
+}
+
+func TestInputMatchesAllWithLimit(t *testing.T) {
+	setNeedles(t, "foo", "bar")
+	defer func(all bool, count int64) { *requireAll, *maxCount = all, count }(*requireAll, *maxCount)
+	*requireAll, *maxCount = true, 1
+
+	cases := []struct {
+		data string
+		want bool
+	}{
+		{"foo bar", true},
+		{"foo foo foo bar", true},
+		{"bar then foo", true},
+		{"foo foo", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got, err := inputMatches(inputOf(c.data)); err != nil || got != c.want {
+			t.Error(fmt.Sprintf("-all -m 1 on %q gave %v, %v; expected %v", c.data, got, err, c.want))
+		}
+	}
+}
+
+// A reader that counts the bytes read from it.
+type countingReader struct {
+	r     io.Reader
+	count int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count += n
+	return n, err
+}
+
+func TestMaxCountTotalStopsSearch(t *testing.T) {
+	setNeedles(t, "foo")
+	defer func(total int64) { *maxCountTotal, totalMatches = total, 0 }(*maxCountTotal)
+	*maxCountTotal = 2
+
+	data := strings.Repeat("foo\n", 1<<20)
+	for _, lines := range []bool{false, true} {
+		totalMatches = 0
+		r := &countingReader{strings.NewReader(data), 0}
+		in := &input{"data", substr.NewHaystackReader(r), nil, -1, ioutil.Discard, nil, 0, 0}
+
+		found := 0
+		if lines {
+			found = processLines(in)
+		} else {
+			for m := range findMatches(in) {
+				if m.Error != nil {
+					t.Error(fmt.Sprintf("got unexpected error %s", m.Error))
+				}
+				found++
+			}
+		}
+		if found != 2 || r.count > len(data)/4 {
+			t.Error(fmt.Sprintf("with -lines=%v, got %d matches after reading %d of %d bytes; expected 2, found near the start", lines, found, r.count, len(data)))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+This file implements sift's budget of open files (-max-open). Each file
+and directory sift opens while searching takes one of a fixed number of
+slots, waiting for one to be free if need be, so that many workers (-j)
+do not exhaust the descriptors the system allows. Should the system run
+out all the same, opening waits and tries again rather than failing.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"myerr"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	open_retry_start = time.Millisecond       // the first wait after running out of descriptors
+	open_retry_max   = 100 * time.Millisecond // the longest single wait
+	open_retry_limit = time.Minute            // how long to keep trying before failing
+)
+
+// one element for each file open; nil if there is no budget
+var openSlots chan bool
+
+// Sets up the budget given by -max-open or, if that is 0, the default for
+// the system. Exits if -max-open is negative.
+func setupOpenBudget() {
+	if *maxOpen < 0 {
+		myerr.MyImmediateFatal(status_fatal_error, "error: -max-open may not be negative")
+	}
+	budget := *maxOpen
+	if budget == 0 {
+		budget = defaultOpenBudget()
+	}
+	if budget > 0 {
+		openSlots = make(chan bool, budget)
+	}
+}
+
+// Opens the file or directory at path for reading, once there is room in
+// the budget. Must be matched by a call to closeFile.
+func openFile(path string) (*os.File, error) {
+	if openSlots != nil {
+		openSlots <- true
+	}
+
+	wait, waited := open_retry_start, time.Duration(0)
+	for {
+		f, err := os.Open(path)
+		if err == nil || !outOfDescriptors(err) || waited >= open_retry_limit {
+			if err != nil && openSlots != nil {
+				<-openSlots
+			}
+			return f, err
+		}
+		time.Sleep(wait)
+		waited += wait
+		if wait *= 2; wait > open_retry_max {
+			wait = open_retry_max
+		}
+	}
+}
+
+// Closes a file opened by openFile, freeing its place in the budget.
+func closeFile(f *os.File) error {
+	err := f.Close()
+	if openSlots != nil {
+		<-openSlots
+	}
+	return err
+}
+
+// Did an open fail because the process or the system has too many files
+// open?
+func outOfDescriptors(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
@@ -0,0 +1,32 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+/*
+This file implements the default budget of open files on systems that
+limit them per process.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"syscall"
+)
+
+// a budget beyond which there is nothing to be gained
+const max_open_budget = 1 << 16
+
+// Returns half the number of files the process may have open, leaving the
+// rest for the standard streams, -o, and the like; 0 if that is unknown.
+func defaultOpenBudget() int {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0
+	}
+	if limit.Cur/2 > max_open_budget {
+		return max_open_budget
+	}
+	return int(limit.Cur / 2)
+}
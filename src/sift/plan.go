@@ -0,0 +1,51 @@
+/*
+This file implements sift's -swap output: a plan, in the JSON format
+defined by the patch package, for the swap tool to verify and apply.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"patch"
+)
+
+// Writes the plan for changing every match within input, if there are any.
+// A file in which an error occurs gets no plan.
+func writeSwapPlan(input *input) {
+	fp := &patch.FilePlan{Path: input.path, Size: input.size}
+	for m := range findMatches(input) {
+		if m.Error != nil {
+			searchError(input.path, m.Error)
+			fp = nil
+		} else if fp != nil {
+			fp.Edits = append(fp.Edits, patch.FileEdit{Offset: m.Offset, Expected: patch.Hex(m.data)})
+		}
+	}
+	if fp == nil || len(fp.Edits) == 0 {
+		return
+	}
+
+	if info, err := os.Stat(input.path); err == nil && info.Mode().IsRegular() {
+		modified := info.ModTime()
+		fp.ModTime = &modified
+	}
+	if *swapChecksum && input.ra != nil {
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(input.ra, 0, input.size)); err != nil {
+			searchError(input.path, err)
+			return
+		}
+		fp.SHA256 = hex.EncodeToString(h.Sum(nil))
+	}
+	noteFound(input.path)
+	if err := patch.WriteFilePlan(input.out, fp); err != nil {
+		fileError(input.path, "write", err, "error: could not write the plan for %s; %s", input.path, err)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+This file implements sift's progress reporting (the -progress flag): a
+status line on the standard error, updated periodically, giving the files
+and bytes searched so far, the throughput, and, once every input has been
+found, an estimate of the time remaining.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const progress_interval = time.Second
+
+// the counts behind the status line; updated atomically, as workers share
+// them
+var progressCounts struct {
+	filesFound, filesDone int64
+	bytesFound, bytesDone int64
+	walkDone              int32 // set once every input has been found
+}
+
+var (
+	progressStart time.Time
+	progressStop  chan bool
+	progressDone  chan bool
+)
+
+// Notes that a file of size bytes is to be searched.
+func noteFileFound(size int64) {
+	atomic.AddInt64(&progressCounts.filesFound, 1)
+	atomic.AddInt64(&progressCounts.bytesFound, size)
+}
+
+// Notes that a file of size bytes has been searched.
+func noteFileDone(size int64) {
+	atomic.AddInt64(&progressCounts.filesDone, 1)
+	atomic.AddInt64(&progressCounts.bytesDone, size)
+}
+
+// Notes that every input has been found, so that the time remaining can be
+// estimated.
+func noteWalkDone() {
+	atomic.StoreInt32(&progressCounts.walkDone, 1)
+}
+
+// Starts updating the status line, if -progress was given.
+func startProgress() {
+	if !*showProgress {
+		return
+	}
+
+	progressStart = time.Now()
+	progressStop = make(chan bool)
+	progressDone = make(chan bool)
+	go func() {
+		defer close(progressDone)
+		ticker := time.NewTicker(progress_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				printProgress(false)
+			case <-progressStop:
+				printProgress(true)
+				return
+			}
+		}
+	}()
+}
+
+// Stops updating the status line, leaving the final counts displayed.
+func stopProgress() {
+	if progressStop == nil {
+		return
+	}
+	close(progressStop)
+	<-progressDone
+}
+
+// Writes the status line to the standard error. On a terminal the line is
+// redrawn in place until final is true.
+func printProgress(final bool) {
+	elapsed := time.Since(progressStart)
+	filesFound := atomic.LoadInt64(&progressCounts.filesFound)
+	filesDone := atomic.LoadInt64(&progressCounts.filesDone)
+	bytesFound := atomic.LoadInt64(&progressCounts.bytesFound)
+	bytesDone := atomic.LoadInt64(&progressCounts.bytesDone)
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(bytesDone) / elapsed.Seconds()
+	}
+	status := fmt.Sprintf("%d/%d files, %s/%s, %s/s",
+		filesDone, filesFound, formatSize(bytesDone), formatSize(bytesFound), formatSize(int64(rate)))
+	if final {
+		status += fmt.Sprintf(", %v", elapsed.Truncate(time.Millisecond))
+	} else if atomic.LoadInt32(&progressCounts.walkDone) != 0 && rate > 0 {
+		remaining := time.Duration(float64(bytesFound-bytesDone) / rate * float64(time.Second))
+		status += fmt.Sprintf(", ETA %v", remaining.Truncate(time.Second))
+	}
+
+	if isTerminal(os.Stderr) {
+		end := ""
+		if final {
+			end = "\n"
+		}
+		fmt.Fprintf(os.Stderr, "\r\x1b[Kprogress: %s%s", status, end)
+	} else {
+		fmt.Fprintf(os.Stderr, "progress: %s\n", status)
+	}
+}
+
+// Returns size in bytes in human-readable form, e.g., "3.5MB".
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	value, suffix := float64(size), "KMGTPE"
+	i := -1
+	for value >= unit && i < len(suffix)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f%cB", value, suffix[i])
+}
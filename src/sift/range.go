@@ -0,0 +1,73 @@
+/*
+This file implements the searching of part of each input (the -start and
+-end flags), e.g., of one region of a raw disk.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"substr"
+)
+
+// Was -start or -end given?
+func rangeGiven() bool {
+	return *rangeStart > 0 || *rangeEnd > 0
+}
+
+// Restricts the search of input to the bytes from -start (or input.base,
+// if that is later) up to -end. Offsets are still reported from the
+// beginning of the input.
+func restrictRange(input *input) error {
+	if !rangeGiven() && input.base == 0 {
+		return nil
+	}
+
+	start, end := *rangeStart, *rangeEnd
+	if int64(input.base) > start {
+		start = int64(input.base)
+	}
+	if input.ra != nil && input.size >= 0 {
+		if end <= 0 || end > input.size {
+			end = input.size
+		}
+		if start > end {
+			start = end
+		}
+		input.haystack = substr.NewHaystackReaderAt(io.NewSectionReader(input.ra, start, end-start), end-start)
+		input.size = end
+	} else {
+		r, err := input.haystack.Reader()
+		if err != nil {
+			return err
+		}
+		if _, err = io.CopyN(ioutil.Discard, r, start); err != nil && err != io.EOF {
+			return err
+		}
+		if end > 0 {
+			r = io.LimitReader(r, end-start)
+		}
+		input.haystack = substr.NewHaystackReader(r)
+	}
+
+	input.base = uint64(start)
+	input.opts = append(input.opts, substr.WithBaseOffset(input.base))
+	return nil
+}
+
+// Returns the size of the device f, or -1 if it cannot be determined.
+func deviceSize(f *os.File) int64 {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil || size <= 0 {
+		return -1
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return -1
+	}
+	return size
+}
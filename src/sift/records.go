@@ -0,0 +1,50 @@
+/*
+This file implements sift's fixed-size records (-record-size): offsets
+are then reported as the number of the record (counting from 0) and the
+offset within it, written record+offset, and with -record-whole only
+matches that lie entirely within one record are reported.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"myerr"
+	"strconv"
+)
+
+// Checks -record-size and -record-whole. Exits if they are not valid.
+func checkRecords() {
+	if *recordSize < 0 {
+		myerr.MyImmediateFatal(status_fatal_error, "error: -record-size may not be negative")
+	}
+	if *recordWhole && *recordSize == 0 {
+		myerr.MyImmediateFatal(status_fatal_error, "error: -record-whole requires -record-size")
+	}
+}
+
+// Returns offset as it is reported: as record+offset with -record-size,
+// otherwise as is.
+func formatOffset(offset uint64) string {
+	if *recordSize == 0 {
+		return strconv.FormatUint(offset, 10)
+	}
+	size := uint64(*recordSize)
+	return strconv.FormatUint(offset/size, 10) + "+" + strconv.FormatUint(offset%size, 10)
+}
+
+// Are matches that straddle records to be dropped?
+func filteringRecords() bool {
+	return *recordWhole
+}
+
+// Is a match of length bytes at offset to be reported? Not with
+// -record-whole if it extends beyond the record in which it begins.
+func withinRecord(offset uint64, length int) bool {
+	if !*recordWhole {
+		return true
+	}
+	return offset%uint64(*recordSize)+uint64(length) <= uint64(*recordSize)
+}
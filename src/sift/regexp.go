@@ -0,0 +1,122 @@
+/*
+This file implements sift's regular expression mode (the -e flag), for
+when a literal needle is not expressive enough. The input is read in
+chunks, each of which is searched with the regexp package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"io"
+	"myerr"
+	"regexp"
+	"substr"
+)
+
+const (
+	// how much of the input is searched at a time
+	regexp_chunk_size = 1024 * 1024
+
+	// A match that begins within this many bytes of the end of a chunk (or
+	// that reaches the end of it) is not reported until the following
+	// bytes have been read, since it might continue, or an earlier match
+	// begin, in them. Matches longer than this are still found, by growing
+	// the buffer, but may be reported at a later offset than they would be
+	// if the whole input were searched at once.
+	regexp_overlap = 64 * 1024
+
+	// How many of the bytes already searched are kept before each chunk
+	// after the first, so that \b and (?m)^ see the byte before it, and ^
+	// and \A do not take its start for the start of the input. A match
+	// that begins within them is not reported.
+	regexp_lookbehind = 1
+)
+
+// the regular expression given by -e, if any
+var expression *regexp.Regexp
+
+// Compiles the -e parameter, which may not match the empty string.
+func compileExpression(expr string) {
+	var err error
+	if expression, err = regexp.Compile(expr); err != nil {
+		myerr.MyImmediateFatal(status_fatal_error, "error: bad regular expression -- %s", err)
+	}
+	if expression.MatchString("") {
+		myerr.MyImmediateFatal(status_fatal_error, "error: the regular expression \"%s\" matches the empty string", expr)
+	}
+	patterns = append(patterns, pattern{label: expr})
+}
+
+// Searches r for expression, calling emit with each match in offset order
+// until emit returns false or r is exhausted.
+func searchRegexp(r io.Reader, emit func(match) bool) error {
+	buffer := make([]byte, regexp_chunk_size)
+	base := uint64(0) // offset within r of buffer[0]
+	used := 0
+	prefix := 0 // how many bytes at the start of buffer were already searched
+	eof := false
+
+	for !eof {
+		if used == len(buffer) {
+			larger := make([]byte, 2*len(buffer))
+			copy(larger, buffer)
+			buffer = larger
+		}
+		count, err := io.ReadFull(r, buffer[used:])
+		used += count
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			eof = true
+		} else if err != nil {
+			return err
+		}
+
+		// the matches that begin before cut are complete and reported;
+		// the rest are searched for again once more has been read
+		cut := used
+		if !eof {
+			cut = used - regexp_overlap
+			if cut < 0 {
+				cut = 0
+			}
+		}
+
+		next := prefix // where the search resumes in the next chunk
+		for _, loc := range expression.FindAllIndex(buffer[:used], -1) {
+			if loc[0] < prefix {
+				continue
+			}
+			if !eof && (loc[0] >= cut || loc[1] == used) {
+				if loc[0] < cut {
+					cut = loc[0]
+				}
+				break
+			}
+			data := append([]byte(nil), buffer[loc[0]:loc[1]]...)
+			m := match{substr.Result{Offset: base + uint64(loc[0])}, data}
+			if !emit(m) {
+				return nil
+			}
+			next = loc[1]
+		}
+		if eof {
+			break
+		}
+
+		if next < cut {
+			next = cut
+		}
+		keep := next - regexp_lookbehind
+		if keep < 0 {
+			keep = 0
+		}
+		copy(buffer, buffer[keep:used])
+		base += uint64(keep)
+		used -= keep
+		prefix = next - keep
+	}
+
+	return nil
+}
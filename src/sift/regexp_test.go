@@ -0,0 +1,63 @@
+/*
+This file includes tests for sift's regular expression mode.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+// Returns the offsets of the matches of expr in data found by
+// searchRegexp.
+func regexpOffsets(t *testing.T, expr string, data []byte) []uint64 {
+	defer func(e *regexp.Regexp) { expression = e }(expression)
+	expression = regexp.MustCompile(expr)
+
+	var offsets []uint64
+	err := searchRegexp(bytes.NewReader(data), func(m match) bool {
+		offsets = append(offsets, m.Offset)
+		return true
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("got unexpected error %s for %s", err, expr))
+	}
+	return offsets
+}
+
+func TestRegexpChunkBoundaries(t *testing.T) {
+	// the second chunk begins where the first's overlap does
+	chunkStart := regexp_chunk_size - regexp_overlap
+	data := bytes.Repeat([]byte("x"), 2000000)
+	copy(data[chunkStart:], "foo")
+	copy(data[regexp_chunk_size-3:], "foobar") // across the end of the first chunk read
+	data[2*chunkStart-1] = '\n'
+	copy(data[2*chunkStart:], "foo")
+	copy(data[len(data)-3:], "foo")
+
+	cases := []struct {
+		expr string
+		want []uint64
+	}{
+		{"foo", []uint64{uint64(chunkStart), regexp_chunk_size - 3, uint64(2 * chunkStart), uint64(len(data) - 3)}},
+		{"foobar", []uint64{regexp_chunk_size - 3}},
+		{"^foo", nil},
+		{`\Afoo`, nil},
+		{`\bfoo`, []uint64{uint64(2 * chunkStart)}},
+		{"(?m)^foo", []uint64{uint64(2 * chunkStart)}},
+		{`foo\z`, []uint64{uint64(len(data) - 3)}},
+		{"^x", []uint64{0}},
+	}
+	for _, c := range cases {
+		if got := regexpOffsets(t, c.expr, data); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Error(fmt.Sprintf("%s found %v; expected %v", c.expr, got, c.want))
+		}
+	}
+}
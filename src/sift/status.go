@@ -0,0 +1,126 @@
+/*
+This file implements sift's exit status, which tells whether anything was
+reported and whether any input could not be searched:
+
+	0  matches were found (or, with -v, inputs without them)
+	1  nothing was found
+	2  a fatal error ended the run
+	3  the run completed, but some inputs could not be searched
+
+The last takes precedence over the first two, so that automation can tell
+a clean run from one that skipped unreadable files.
+
+It also implements the reporting of inputs that cannot be searched, as
+text or, with -errors json, as JSON records, one per line.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"myerr"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	status_found       = 0
+	status_none_found  = 1
+	status_fatal_error = 2
+	status_file_errors = 3
+)
+
+// A report of an error in the form written by -errors json.
+type errorRecord struct {
+	Path    string `json:"path"`
+	Op      string `json:"op"`
+	Errno   int    `json:"errno,omitempty"`
+	Message string `json:"message"`
+}
+
+// the errors reported by fileError in place of a more specific one
+var (
+	errDeadline     = errors.New("not searched; the -deadline passed")
+	errNotRecursive = errors.New("is a directory, and -r was not given")
+)
+
+var (
+	errorOutput io.Writer = os.Stderr // where fileError reports: stderr, or -errors-file
+	errorMutex  sync.Mutex
+)
+
+// set once anything is reported, or any input cannot be searched; updated
+// atomically, as workers share them
+var foundAny, fileErrors int32
+
+// Notes that a match (or, with -v, an input without one) was reported for
+// the input at path.
+func noteFound(path string) {
+	atomic.StoreInt32(&foundAny, 1)
+	noteReported(path)
+}
+
+// Reports an error that kept the input at path, or part of it, from being
+// searched, unless -no-messages was given, and notes it for the exit
+// status. In text form (the default) the report is formatString expanded
+// with elements; with -errors json it is a record of path, the operation
+// op that failed, and err.
+func fileError(path, op string, err error, formatString string, elements ...interface{}) {
+	atomic.StoreInt32(&fileErrors, 1)
+	if *noMessages {
+		return
+	}
+
+	errorMutex.Lock()
+	defer errorMutex.Unlock()
+	if *errorFormat != "json" {
+		fmt.Fprintf(errorOutput, formatString, elements...)
+		fmt.Fprintln(errorOutput)
+		return
+	}
+
+	record := errorRecord{Path: path, Op: op, Message: err.Error()}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		record.Errno = int(errno)
+	}
+	json.NewEncoder(errorOutput).Encode(record)
+}
+
+// Reports an error that occurred while searching the input at path.
+func searchError(path string, err error) {
+	fileError(path, "search", err, "%s: error -- %s", path, err)
+}
+
+// Checks -errors and opens -errors-file, if given. Exits if either is not
+// valid.
+func setupErrors() {
+	if *errorFormat != "text" && *errorFormat != "json" {
+		myerr.MyImmediateFatal(status_fatal_error, "error: -errors must be text or json, not %q", *errorFormat)
+	}
+	if *errorFile != "" {
+		f, err := os.Create(*errorFile)
+		if err != nil {
+			myerr.MyImmediateFatal(status_fatal_error, "error: could not create %s; %s", *errorFile, err)
+		}
+		errorOutput = f
+	}
+}
+
+// Returns the status with which a completed run exits.
+func exitStatus() int {
+	if atomic.LoadInt32(&fileErrors) != 0 {
+		return status_file_errors
+	} else if atomic.LoadInt32(&foundAny) != 0 {
+		return status_found
+	}
+	return status_none_found
+}
@@ -10,166 +10,438 @@ See: http://creativecommons.org/licenses/by-sa/3.0/
 package main
 
 import (
-	ba "bytearray"
+	"bufio"
+	"bytes"
+	"decompress"
+	"fileutil"
 	"flag"
 	"fmt"
 	"io"
 	"myerr"
 	"os"
+	"path/filepath"
+	"runtime"
 	"substr"
+	"time"
 )
 
-const (
-	status_found       = 0
-	status_none_found  = 1
-	status_fatal_error = 2
-)
+// where the results are written: stdout, or the file given by -o
+var output io.Writer = os.Stdout
+
+// memory-mapped files at least this large are searched in parallel
+const parallel_threshold = 64 * 1024 * 1024
 
 var statFunction func (string) (os.FileInfo, error)
 
-var needleString *string = flag.String("t", "", "text to look for within input(s)")
 var findAll *bool = flag.Bool("a", false, "display all matching offsets")
 var recursive *bool = flag.Bool("r", false, "recursively descend directories")
+var countTotal *bool = flag.Bool("count-total", false, "like -c, but display only the total number of matches in all inputs")
+var countDirs *bool = flag.Bool("count-dirs", false, "like -c, but display the number of matches in the files directly within each directory, then the total")
 var displayCount *bool = flag.Bool("c", false, "display count of matches")
-var quiet *bool = flag.Bool("q", false, "quiet; exit immediatly with status 0 if any matches found")
-var processStdin *bool = flag.Bool("stdin", false, "process stdin as one of the inputs")
-var swapOutput *bool = flag.Bool("swap", false, "output in format for swap tool")
-var followSymbolicLinks *bool = flag.Bool("L", false, "follow symbolic links")
-
-var needleBytes ba.ByteArray
-var needle *substr.Needle
-
-func processReader(path string, in io.Reader, in_size int64) {
-	if *displayCount {
-		count := findCount(path, substr.IndexesWithinReaderNeedle(in, needle))
-		fmt.Printf("%s: %d\n", path, count)
+var noMessages *bool = flag.Bool("no-messages", false, "do not report inputs that cannot be searched (e.g., that do not exist or cannot be read); the exit status still reflects them")
+var errorFormat *string = flag.String("errors", "text", "how inputs that cannot be searched are reported: text, or json for one record per line giving the path, the operation that failed (op), the error number (errno), and the message")
+var errorFile *string = flag.String("errors-file", "", "report inputs that cannot be searched to this file instead of the standard error")
+var quiet *bool = flag.Bool("q", false, "quiet; exit immediately with status 0 as soon as any match is found, without searching further inputs")
+var processStdin *bool = flag.Bool("stdin", false, "process stdin as one of the inputs (the default if no inputs are named and stdin is not a terminal)")
+var namesOnly *bool = flag.Bool("names", false, "match the needles, or the regular expression, against the names of files and directories instead of the contents of files, listing those that match")
+var namesAlso *bool = flag.Bool("names-also", false, "like -names, but search the contents of files as well")
+var listFileTypes *bool = flag.Bool("type-list", false, "list the file types known to -type, and exit")
+var dedupe *bool = flag.Bool("dedupe", false, "search only one of each set of files with the same content (judged by size and samples of it), then list the others of each set reported")
+var checkpointPath *string = flag.String("checkpoint", "", "record in this file each file whose results have been written, so that an interrupted run can be continued with -resume; the file is removed once the run completes")
+var resume *bool = flag.Bool("resume", false, "continue the run recorded by -checkpoint, skipping the files already searched")
+var allEncodings *bool = flag.Bool("all-encodings", false, "also search for each text needle (-t, -T, -tf) encoded as UTF-16LE and UTF-16BE")
+var swapOutput *bool = flag.Bool("swap", false, "output a plan for the swap tool, as one line of JSON per file giving the offsets, the bytes found at them, and the file's size and modification time")
+var swapChecksum *bool = flag.Bool("swap-sha256", false, "with -swap, also give the SHA-256 of each file in the plan, so that swap can tell whether it has changed since; each file is read again")
+var swapV1 *bool = flag.Bool("swap-v1", false, "with -swap, output the original format for the swap tool: the quoted path followed by the offsets")
+var includeHidden *bool = flag.Bool("hidden", false, "when descending directories, also search files and directories whose names begin with a dot, which are otherwise skipped")
+var verbose *bool = flag.Bool("verbose", false, "report each input searched on the standard error (swap's -v; here -v means invert)")
+var debugOutput *bool = flag.Bool("debug", false, "like -verbose, but also report why each input not searched was passed over (e.g., hidden, ignored, or filtered out) and each symbolic link followed")
+var followSymbolicLinks *bool = flag.Bool("L", false, "follow symbolic links; each directory is descended only once, however many links lead to it")
+var format *string = flag.String("format", "", "output each match using a template: %p path, %o offset, %O hex offset, %r offset as record+offset (see -record-size), %n match number, %t the needle matched, %c context, %% a percent sign")
+var lineOutput *bool = flag.Bool("lines", false, "display each matching line as path:line:content")
+var contextBefore *int = flag.Int("B", -1, "display this many bytes of context before each match (files only; not stdin)")
+var contextAfter *int = flag.Int("A", -1, "display this many bytes of context after each match (files only; not stdin)")
+var contextBoth *int = flag.Int("C", -1, "display this many bytes of context before and after each match (files only; not stdin)")
+var contextHex *bool = flag.Bool("hex", false, "display context as hex bytes rather than escaped text")
+var hexDump *bool = flag.Bool("hexdump", false, "display an xxd-style dump of the bytes around each match")
+var requireAll *bool = flag.Bool("all", false, "report an input only if every -t and -b needle occurs within it")
+var invert *bool = flag.Bool("v", false, "invert; display only the paths of inputs that do not match (see -all)")
+var listMatching *bool = flag.Bool("l", false, "display only the paths of inputs that match (see -all)")
+var regexpString *string = flag.String("e", "", "regular expression to look for within input(s), instead of -t or -b")
+var maxDepth *int = flag.Int("max-depth", -1, "descend at most this many levels below the inputs named (as with find; -1 means no limit)")
+var minDepth *int = flag.Int("min-depth", 0, "search only files at least this many levels below the inputs named (as with find)")
+var timeoutPerFile *time.Duration = flag.Duration("timeout-per-file", 0, "stop searching an input after this long (e.g., 30s), reporting it as not completed")
+var deadlineAfter *time.Duration = flag.Duration("deadline", 0, "stop the run after this long (e.g., 10m), reporting the inputs not completed")
+var maxOpen *int = flag.Int("max-open", 0, "have at most this many files and directories open at once while searching, waiting for one to close if need be; 0 means half what the system allows")
+var carveSize *int64 = flag.Int64("carve", 0, "write this many bytes, starting at each match displayed (see -a), to a numbered file of its own in -carve-dir")
+var carveDir *string = flag.String("carve-dir", ".", "the directory to which -carve writes")
+var recordSize *int64 = flag.Int64("record-size", 0, "the input consists of records of this many bytes; display offsets as record+offset, counting records from 0")
+var recordWhole *bool = flag.Bool("record-whole", false, "with -record-size, only report matches that lie entirely within one record")
+var useMmap *bool = flag.Bool("mmap", false, "search files by mapping them into memory; large files are then searched in parallel")
+var maxCount *int64 = flag.Int64("m", 0, "stop searching an input after this many matches (or, with -lines, matching lines); 0 means no limit")
+var maxCountTotal *int64 = flag.Int64("max-count-total", 0, "stop the whole run after this many matches; 0 means no limit")
+var filesFrom *string = flag.String("files-from", "", "also search the inputs listed, one per line, in this file (\"-\" for the standard input)")
+var files0From *string = flag.String("files0-from", "", "like -files-from, but the inputs are terminated by NUL bytes (as from find -print0)")
+var nullOutput *bool = flag.Bool("0", false, "end each path listed by -l or -v, and each record of -c, -format, -lines, and first offset output, with a NUL byte rather than a newline (as for xargs -0)")
+var decompressInputs *bool = flag.Bool("z", false, "search gzip, bzip2, and zstd compressed inputs in their decompressed form; offsets are within the decompressed data")
+var searchArchives *bool = flag.Bool("archives", false, "search the files within tar (optionally compressed) and zip archives, reported as archive!file")
+var binaryMode *string = flag.String("binary", "search", "how to treat binary inputs (those with a NUL byte near the start): search, skip, or list (report only whether they match)")
+var showProgress *bool = flag.Bool("progress", false, "periodically display the files and bytes searched, throughput, and time remaining on the standard error")
+var outputFile *string = flag.String("o", "", "write the results to this file, which is only created (or replaced) once the search is complete, rather than to stdout")
+var noIgnore *bool = flag.Bool("no-ignore", false, "do not skip the paths named by .gitignore and .siftignore files when descending directories")
+var rangeStart *int64 = flag.Int64("start", 0, "search each input from this offset on (with -lines, lines are counted from there)")
+var rangeEnd *int64 = flag.Int64("end", 0, "search each input only up to this offset; 0 means to its end")
+var followMode *bool = flag.Bool("follow", false, "once searched, keep checking the inputs, searching data appended to files and files added to directories, until interrupted")
+var followInterval *time.Duration = flag.Duration("follow-interval", time.Second, "how often -follow checks the inputs")
+var workers *int = flag.Int("j", 1, "search this many files at once")
+var colorMode *string = flag.String("color", "auto", "color paths, offsets, and matches: always, never, or auto (only when output is a terminal and NO_COLOR is not set)")
+
+// An input to be searched: a file or the standard input.
+type input struct {
+	path     string
+	haystack substr.Haystack
+	ra       io.ReaderAt // nil if the input does not allow random access
+	size     int64       // -1 if not known
+	out      io.Writer   // where the results are written
+	opts     []substr.Option
+	base     uint64 // the offset of the first byte searched (see -start)
+	reported uint64 // matches ending at or before this offset were already reported (see -follow)
+}
+
+func processHaystack(input *input) {
+	if err := restrictRange(input); err != nil {
+		searchError(input.path, err)
+		return
+	}
+	if handleBinary(input) {
+		return
+	}
+
+	// in quiet mode the whole run ends as soon as any input qualifies, so
+	// nothing more need be learned about one than whether it does
+	path := input.path
+	if *quiet || *invert || *listMatching {
+		if matched, err := inputMatches(input); err != nil {
+			searchError(path, err)
+		} else if matched != *invert {
+			if *quiet {
+				os.Exit(status_found)
+			}
+			noteFound(path)
+			fmt.Fprint(input.out, colorPath(path), recordEnd())
+		}
+		return
+	}
+
+	if *requireAll && len(patterns) > 1 {
+		if matched, err := inputMatches(input); err != nil {
+			searchError(path, err)
+			return
+		} else if !matched {
+			return
+		}
+	}
+
+	// a stream must be read into memory for its matches to be carved
+	if carving() {
+		if err := slurp(input); err != nil {
+			searchError(path, err)
+			return
+		}
+	}
+
+	in := input.haystack
+	if *lineOutput {
+		if processLines(input) > 0 {
+			noteFound(path)
+		}
+	} else if *format != "" {
+		count := 0
+		for result := range findMatches(input) {
+			if result.Error != nil {
+				searchError(path, result.Error)
+			} else {
+				count++
+				noteFound(path)
+				fmt.Fprint(input.out, expandFormat(*format, input, result, count), recordEnd())
+			}
+		}
+	} else if *displayCount {
+		if len(patterns) == 1 {
+			count := findCount(path, findMatches(input))
+			if count > 0 {
+				noteFound(path)
+			}
+			if aggregatingCounts() {
+				addCount(path, uint64(count))
+			} else {
+				fmt.Fprintf(input.out, "%s: %d%s", colorPath(path), count, recordEnd())
+			}
+		} else {
+			// counted as reported, so that -m and -max-count-total apply
+			counts := make([]uint64, len(patterns))
+			total := uint64(0)
+			for m := range findMatches(input) {
+				if m.Error != nil {
+					searchError(path, m.Error)
+					continue
+				}
+				counts[m.Pattern]++
+				total++
+			}
+			if total > 0 {
+				noteFound(path)
+			}
+			if aggregatingCounts() {
+				addCount(path, total)
+				return
+			}
+			fmt.Fprintf(input.out, "%s: %d%s", colorPath(path), total, recordEnd())
+			for i, count := range counts {
+				fmt.Fprintf(input.out, "    %s: %d%s", patterns[i].label, count, recordEnd())
+			}
+		}
+	} else if *swapOutput && !*swapV1 {
+		writeSwapPlan(input)
 	} else if *swapOutput {
 		found := false
 		gotError := false
-		for result := range substr.IndexesWithinReaderNeedle(in, needle) {
+		for result := range findMatches(input) {
 			if gotError {
 				if result.Error != nil {
-					myerr.MyError("    error: %s", result.Error)
+					fileError(path, "search", result.Error, "    error: %s", result.Error)
 				}
 				continue
 			} else if !found {
 				if result.Error != nil {
-					myerr.MyError("    error: %s", result.Error)
+					fileError(path, "search", result.Error, "    error: %s", result.Error)
 					gotError = true
 				} else {
-					fmt.Printf("\"%s\" %d", path, result.Offset)
+					noteFound(path)
+					fmt.Fprintf(input.out, "\"%s\" %d", path, result.Offset)
 					found = true
 				}
 			} else {
 				if result.Error != nil {
-					fmt.Println()
-					myerr.MyError("    error: %s", result.Error)
+					fmt.Fprintln(input.out)
+					fileError(path, "search", result.Error, "    error: %s", result.Error)
 					gotError = true
 				} else {
-					fmt.Printf(" %d", result.Offset)
+					fmt.Fprintf(input.out, " %d", result.Offset)
 				}
 			}
 		}
 		if found && !gotError {
-			fmt.Println()
+			fmt.Fprintln(input.out)
 		}
 	} else if *findAll {
 		count := 0
-		for result := range substr.IndexesWithinReaderNeedle(in, needle) {
+		width := calcWidth(in.Size())
+		for result := range findMatches(input) {
 			if count == 0 {
-				fmt.Printf("%s:\n", path)
+				fmt.Fprintf(input.out, "%s:\n", colorPath(path))
 			}
 			count++
 			if result.Error != nil {
-				myerr.MyError("    error: %s", result.Error)
+				fileError(path, "search", result.Error, "    error: %s", result.Error)
 			} else {
-				fmt.Printf("    match %3d at offset %*d\n", count, calcWidth(in_size), result.Offset)
+				noteFound(path)
+				fmt.Fprintf(input.out, "    match %3d at offset %s%s%s\n", count, colorOffset(fmt.Sprintf("%*s", width, formatOffset(result.Offset))), patternTag(result), contextSuffix(input, result))
+				dumpMatch(input, result)
+				carveMatch(input, result)
 			}
 		}
 	} else {
-		found, offset, err := substr.IndexWithinReaderNeedle(in, needle)
+		found, first, err := findFirstMatch(input)
 		if err != nil {
-			myerr.MyError("%s: error -- %s", path, err)
+			searchError(path, err)
 		} else if found {
-			if *quiet {
-				os.Exit(status_found)
-			} else {
-				fmt.Printf("%s: first offset %d\n", path, offset)
-			}
+			noteFound(path)
+			fmt.Fprintf(input.out, "%s: first offset %s%s%s%s", colorPath(path), colorOffset(formatOffset(first.Offset)), patternTag(first), contextSuffix(input, first), recordEnd())
+			dumpMatch(input, first)
+			carveMatch(input, first)
 		}
 	}
 }
 
 // process entry of given name in current directory; recursively descend if
-// entry names a directory and the recursive flag is set
-func processInputs(entry, accumulatedPath string) {
+// entry names a directory and the recursive flag is set; depth is 0 for the
+// inputs named on the command line and one more for each directory descended;
+// ignores holds the rules of the ignore files of the directories above
+func processInputs(entry, accumulatedPath string, depth int, ignores *ignoreList) {
 	var err error
 	var info os.FileInfo
 
-	if info, err = statFunction(entry); err != nil {
-		myerr.MyError("error: %s", err)
+	if deadlinePassed() {
+		fileError(accumulatedPath, "search", errDeadline, "%s: not searched; the -deadline passed", accumulatedPath)
 		return
 	}
+	if depth > 0 && hidden(entry) {
+		myerr.Debug("%s: skipped; hidden (see -hidden)", accumulatedPath)
+		return
+	}
+	if info, err = statFunction(accumulatedPath); err != nil {
+		fileError(accumulatedPath, "stat", err, "error: %s", err)
+		return
+	}
+	if *followSymbolicLinks && myerr.Enabled(myerr.LevelDebug) {
+		if link, e := os.Lstat(accumulatedPath); e == nil && link.Mode()&os.ModeSymlink != 0 {
+			myerr.Debug("%s: following symbolic link", accumulatedPath)
+		}
+	}
 	
-	// skip over non-regular files and non-directories
-	if 0 != info.Mode() & (os.ModeSymlink | os.ModeNamedPipe | os.ModeSocket | os.ModeDevice) {
+	// skip over non-regular files and non-directories, except that devices
+	// (e.g., disks) are searched when named on the command line
+	skipped := os.ModeSymlink | os.ModeNamedPipe | os.ModeSocket | os.ModeDevice
+	if depth == 0 {
+		skipped &^= os.ModeDevice
+	}
+	if 0 != info.Mode() & skipped {
+		myerr.Debug("%s: skipped; not a regular file or directory", accumulatedPath)
+		return
+	}
+
+	if depth > 0 && ignores.ignored(accumulatedPath, info.IsDir()) {
+		myerr.Debug("%s: skipped; ignored by an ignore file (see -no-ignore)", accumulatedPath)
 		return
 	}
 
 	if info.IsDir() {
+		if depth > 0 && excludedDir(entry) {
+			myerr.Debug("%s: skipped; excluded by -exclude-dir", accumulatedPath)
+			return
+		}
+		if depth > 0 && deepEnough(depth) {
+			matchName(entry, accumulatedPath)
+		}
 		if !*recursive {
-			myerr.MyError("%s is a directory without recursive flag", accumulatedPath)
+			fileError(accumulatedPath, "descend", errNotRecursive, "%s is a directory without recursive flag", accumulatedPath)
 			return
 		}
-
-		var thisDir string
-		if thisDir, err = os.Getwd(); err != nil {
-			myerr.MyPanic(err)
+		if !descendInto(depth) {
+			myerr.Debug("%s: not descended; -max-depth reached", accumulatedPath)
+			return
+		}
+		if !firstVisit(accumulatedPath) {
+			myerr.Debug("%s: not descended; already visited", accumulatedPath)
+			return
 		}
-		myerr.MyPanic(os.Chdir(entry))
-		defer func() {
-			myerr.MyPanic(os.Chdir(thisDir))
-		}()
 
 		var f *os.File
-		if f, err = os.Open("."); err != nil {
-			myerr.MyError("error: could not open directory %s; %s", accumulatedPath, err)
+		if f, err = openFile(accumulatedPath); err != nil {
+			fileError(accumulatedPath, "open", err, "error: could not open directory %s; %s", accumulatedPath, err)
 			return
 		}
 		var entries_info []os.FileInfo
 		if entries_info, err = f.Readdir(-1); err != nil {
-			myerr.MyPanic(f.Close())
-			myerr.MyError("error: could not read directory %s; %s", accumulatedPath, err)
+			myerr.MyPanic(closeFile(f))
+			fileError(accumulatedPath, "read", err, "error: could not read directory %s; %s", accumulatedPath, err)
 			return
 		}
-		myerr.MyPanic(f.Close())
+		myerr.MyPanic(closeFile(f))
 
+		ignores = readIgnores(ignores, accumulatedPath)
 		for _, entry := range entries_info {
 			newAccumulatedPath := fmt.Sprintf("%s%c%s", accumulatedPath, os.PathSeparator, entry.Name())
-			processInputs(entry.Name(), newAccumulatedPath)
+			processInputs(entry.Name(), newAccumulatedPath, depth+1, ignores)
 		}
+	} else if by := filteredBy(entry, info, depth); by != "" {
+		myerr.Debug("%s: skipped; filtered out by %s", accumulatedPath, by)
 	} else {
-		var f *os.File
-		var e error
-		if f, e = os.Open(entry); e != nil {
-			myerr.MyError("warning: could not open %s; skipping", accumulatedPath)
+		matchName(filepath.Base(entry), accumulatedPath)
+		if !searchingContents() {
+			return
+		}
+		if firstOfContent(accumulatedPath, info.Size()) {
+			scheduleFile(accumulatedPath, info.Size())
+		} else {
+			myerr.Debug("%s: skipped; the same content as a file already searched (see -dedupe)", accumulatedPath)
+		}
+	}
+}
+
+// search the file at path from offset from on, writing the results to out;
+// matches ending at or before reported are not written
+func processFile(path string, from, reported int64, out io.Writer) {
+	if limitReached() {
+		return
+	}
+	if deadlinePassed() {
+		fileError(path, "search", errDeadline, "%s: not searched; the -deadline passed", path)
+		return
+	}
+	myerr.Info("searching %s", path)
+	if *searchArchives && processArchive(path, out) {
+		return
+	}
+
+	var f *os.File
+	var e error
+	if f, e = openFile(path); e != nil {
+		fileError(path, "open", e, "warning: could not open %s; skipping", path)
+		return
+	}
+
+	defer func() {
+		closeFile(f)
+	}()
+
+	in := substr.NewHaystackFile(f)
+	if in.Size() < 0 {
+		if size := deviceSize(f); size >= 0 {
+			in = substr.NewHaystackReaderAt(f, size)
+		}
+	}
+	r, ra := withBudget(f, f)
+	if budgeted() {
+		if in.Size() < 0 {
+			in = substr.NewHaystackReader(r)
+		} else {
+			in = substr.NewHaystackReaderAt(ra, in.Size())
+		}
+	}
+	if *decompressInputs {
+		r, format, err := decompress.NewReader(r)
+		if err != nil {
+			fileError(path, "decompress", err, "warning: could not decompress %s; skipping -- %s", path, err)
 			return
 		}
+		if format != decompress.None || in.Size() < 0 {
+			processHaystack(&input{path, substr.NewHaystackReader(r), nil, -1, out, nil, 0, 0})
+			return
+		}
+	}
 
-		defer func() {
-			f.Close()
-		}()
+	input := &input{path, in, ra, in.Size(), out, nil, uint64(from), uint64(reported)}
+	if *useMmap && input.size > 0 && !budgeted() {
+		if data, err := mapFile(f, input.size); err != nil {
+			myerr.MyError("warning: could not map %s; reading it instead -- %s", path, err)
+		} else {
+			defer unmapFile(data)
+			input.haystack = substr.NewHaystackBytes(data)
+			input.ra = bytes.NewReader(data)
+			if input.size >= parallel_threshold {
+				input.opts = []substr.Option{substr.WithParallel(runtime.NumCPU())}
+			}
+		}
+	}
+	processHaystack(input)
+}
 
-		processReader(accumulatedPath, f, info.Size())
+// returns what ends a record of output: a newline, or a NUL with -0
+func recordEnd() string {
+	if *nullOutput {
+		return "\x00"
 	}
+	return "\n"
 }
 
 // count the results coming in through a channel and report the final amount
-func findCount(path string, results <-chan substr.Result) int {
+func findCount(path string, results <-chan match) int {
 	count := 0
 	for r := range results {
 		if r.Error != nil {
-			myerr.MyError("%s: error -- %s", path, r.Error)
+			searchError(path, r.Error)
 		} else {
 			count++
 		}
@@ -189,19 +461,67 @@ func calcWidth(max int64) int {
 }
 
 func main() {
-	flag.Var(&needleBytes, "b", "bytes to look for within input(s); e.g., \"-b 00ff00AA\"")
+	flag.Var(textFlag{escapes: false}, "t", "text to look for within input(s); may be repeated")
+	flag.Var(textFlag{escapes: true}, "T", "like -t, but the text may contain the escapes \\n, \\t, \\r, \\0, \\\\, \\\", \\', and \\xNN, and may be enclosed in double quotes")
+	flag.Var(bytesFlag{}, "b", "bytes to look for within input(s); e.g., \"-b 00ff00AA\", or \"@PATH\" for the contents of a file; may be repeated")
+	flag.Var(fileFlag{text: true}, "tf", "file containing text to look for within input(s), less any final end of line; may be repeated")
+	flag.Var(fileFlag{text: false}, "bf", "file containing bytes to look for within input(s); may be repeated")
+	flag.Var(&excludeDirs, "exclude-dir", "when descending directories, skip those whose names match this name or pattern (e.g., .git or \"*.tmp\"); may be repeated")
+	flag.Var(&newerThan, "newer-than", "only search files modified after this time, given as a timestamp (e.g., 2012-06-30 or 2012-06-30T18:00:00) or a duration before now (e.g., 36h)")
+	flag.Var(&olderThan, "older-than", "only search files modified before this time, given as for -newer-than")
+	flag.Var(&typeNames, "type", "when descending directories, only search files of these types (e.g., go,c); may be repeated; -type-list lists them")
+	flag.Var(typeAdder{}, "type-add", "add file name patterns to a type, defining it if need be, as name:pattern[,pattern...] (e.g., web:*.html,*.css); may be repeated")
+	flag.BoolVar(decompressInputs, "decompress", false, "same as -z")
+	flag.BoolVar(nullOutput, "null", false, "same as -0")
+	// -L already means follow symbolic links, so the grep-style names are
+	// offered as long forms only
+	flag.BoolVar(listMatching, "files-with-matches", false, "same as -l")
+	flag.BoolVar(invert, "files-without-match", false, "same as -v")
+	parseDefaults()
 	flag.Parse() // scan the arguments list
 
-	if len(*needleString) != 0 {
-		if len(needleBytes) == 0 {
-			needle = substr.NewNeedleStr(*needleString)
-		} else {
-			myerr.MyFatal(status_fatal_error, "error: specified both -t and -b parameters")
+	if *debugOutput {
+		myerr.SetVerbosity(myerr.LevelDebug)
+	} else if *verbose {
+		myerr.SetVerbosity(myerr.LevelInfo)
+	}
+
+	if *listFileTypes {
+		listTypes(os.Stdout)
+		return
+	}
+
+	if *listMatching && *invert {
+		myerr.MyImmediateFatal(status_fatal_error, "error: specified both -l and -v parameters")
+	}
+
+	if *regexpString != "" {
+		if len(patterns) != 0 {
+			myerr.MyImmediateFatal(status_fatal_error, "error: specified -e along with -t or -b parameters")
 		}
-	} else if len(needleBytes) == 0 {
-		myerr.MyFatal(status_fatal_error, "error: specified neither -t nor -b parameter")
-	} else {
-		needle = substr.NewNeedleBytes(needleBytes)
+		compileExpression(*regexpString)
+	} else if len(patterns) == 0 {
+		myerr.MyImmediateFatal(status_fatal_error, "error: specified neither -t nor -b nor -e parameter")
+	}
+	if *allEncodings {
+		if expression != nil {
+			myerr.MyImmediateFatal(status_fatal_error, "error: specified both -e and -all-encodings parameters")
+		}
+		addEncodings()
+	}
+	if expression == nil {
+		needle := make([]*substr.Needle, 0, len(patterns))
+		for _, p := range patterns {
+			if p.needle.Len() == 0 {
+				myerr.MyImmediateFatal(status_fatal_error, "error: a -t, -b, -tf, or -bf needle is empty")
+			}
+			needle = append(needle, p.needle)
+		}
+		needles = substr.NewNeedleSet(needle...)
+	}
+
+	if *swapOutput && (len(patterns) > 1 || expression != nil) {
+		myerr.MyImmediateFatal(status_fatal_error, "error: the swap flag allows only one -t or -b parameter")
 	}
 	
 	if *followSymbolicLinks {
@@ -210,8 +530,23 @@ func main() {
 		statFunction = os.Lstat
 	}
 
+	setupErrors()
+	startDeadline()
+	setupOpenBudget()
+	setupCarving()
+	checkRecords()
+	setupColor()
+	checkBinaryMode()
+	checkTypes()
+
+	if aggregatingCounts() {
+		*displayCount = true
+	}
+
 	if *quiet {
 		*findAll = false
+		*lineOutput = false
+		*format = ""
 	}
 
 	if *swapOutput {
@@ -220,19 +555,94 @@ func main() {
 
 	inputs := flag.Args()
 
-	if len(inputs) == 0 && !*processStdin {
-		myerr.MyFatal(status_fatal_error, "error: did not specify any input files or directories or provide the standard input flag")
+	listName, listNul := *filesFrom, false
+	if *files0From != "" {
+		if listName != "" {
+			myerr.MyImmediateFatal(status_fatal_error, "error: specified both -files-from and -files0-from parameters")
+		}
+		listName, listNul = *files0From, true
+	}
+	if listName == "-" && *processStdin {
+		myerr.MyImmediateFatal(status_fatal_error, "error: the standard input cannot be both searched and a list of inputs")
 	}
 
-	if *processStdin {
-		processReader("STDIN", os.Stdin, 0)
+	// like grep, search a piped or redirected standard input by default
+	if len(inputs) == 0 && !*processStdin && listName == "" && !isTerminal(os.Stdin) {
+		*processStdin = true
 	}
 
-	for _, fname := range inputs {
-		processInputs(fname, fname)
+	if len(inputs) == 0 && !*processStdin && listName == "" {
+		myerr.MyImmediateFatal(status_fatal_error, "error: did not specify any input files or directories or provide the standard input flag")
 	}
 
-	if *quiet {
-		os.Exit(status_none_found)
+	if *checkpointPath != "" && (*outputFile != "" || *followMode) {
+		myerr.MyImmediateFatal(status_fatal_error, "error: -checkpoint cannot be used with -o or -follow")
+	}
+	if *dedupe && *followMode {
+		myerr.MyImmediateFatal(status_fatal_error, "error: specified both -dedupe and -follow parameters")
+	}
+	if *outputFile != "" && *quiet {
+		myerr.MyImmediateFatal(status_fatal_error, "error: specified both -o and -q parameters")
+	}
+	if *outputFile != "" && *followMode {
+		myerr.MyImmediateFatal(status_fatal_error, "error: specified both -o and -follow parameters")
+	}
+
+	openCheckpoint()
+	if *outputFile == "" {
+		searchInputs(inputs, listName, listNul)
+		if *followMode {
+			followInputs(inputs)
+		}
+	} else {
+		err := fileutil.AtomicCreate(*outputFile, 0644, func(w io.Writer) error {
+			buffered := bufio.NewWriter(w)
+			output = buffered
+			searchInputs(inputs, listName, listNul)
+			return buffered.Flush()
+		})
+		if err != nil {
+			myerr.MyImmediateFatal(status_fatal_error, "error: could not write %s; %s", *outputFile, err)
+		}
+	}
+
+	closeCheckpoint()
+	os.Exit(exitStatus())
+}
+
+// search the standard input (if requested), the inputs named, and those
+// listed in the file listName (if any), writing the results to output
+func searchInputs(inputs []string, listName string, listNul bool) {
+	resetCounts()
+	resetDedupe()
+	if *processStdin && searchingContents() {
+		r, _ := withBudget(os.Stdin, nil)
+		if *decompressInputs {
+			var err error
+			if r, _, err = decompress.NewReader(r); err != nil {
+				myerr.MyImmediateFatal(status_fatal_error, "error: could not decompress STDIN -- %s", err)
+			}
+		}
+		processHaystack(&input{"STDIN", substr.NewHaystackReader(r), nil, -1, output, nil, 0, 0})
+	}
+
+	visitedDirs = map[string]bool{}
+	startProgress()
+	startWorkers()
+	for _, fname := range inputs {
+		processInputs(fname, fname, 0, nil)
+	}
+	if listName != "" {
+		err := readFileList(listName, listNul, func(fname string) {
+			processInputs(fname, fname, 0, nil)
+		})
+		if err != nil {
+			fileError(listName, "read", err, "error: could not read list of inputs %s; %s", listName, err)
+		}
 	}
+	noteWalkDone()
+	finishWorkers()
+	stopProgress()
+	printCounts()
+	printDuplicates()
 }
@@ -0,0 +1,128 @@
+/*
+This file implements sift's file types: named groups of file name
+patterns (e.g., go for *.go) that -type uses to restrict which files are
+searched when descending directories. More can be defined, or existing
+ones extended, with -type-add, typically in the configuration file.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"myerr"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// the file name patterns of each type
+var fileTypes = map[string][]string{
+	"archive": {"*.tar", "*.tgz", "*.tbz2", "*.zip", "*.jar", "*.gz", "*.bz2", "*.xz", "*.zst", "*.7z", "*.rar"},
+	"c":       {"*.c", "*.h"},
+	"cpp":     {"*.cc", "*.cpp", "*.cxx", "*.hh", "*.hpp", "*.hxx", "*.h"},
+	"db":      {"*.db", "*.sqlite", "*.sqlite3", "*.mdb"},
+	"exe":     {"*.exe", "*.dll", "*.sys", "*.so", "*.so.*", "*.dylib", "*.o", "*.a", "*.obj", "*.lib"},
+	"go":      {"*.go"},
+	"html":    {"*.html", "*.htm", "*.xhtml"},
+	"img":     {"*.img", "*.iso", "*.dd", "*.raw", "*.dmg", "*.vmdk", "*.vdi", "*.vhd", "*.vhdx", "*.qcow2"},
+	"java":    {"*.java"},
+	"js":      {"*.js", "*.mjs", "*.jsx"},
+	"json":    {"*.json"},
+	"log":     {"*.log", "*.log.*"},
+	"photo":   {"*.jpg", "*.jpeg", "*.png", "*.gif", "*.bmp", "*.tif", "*.tiff", "*.webp"},
+	"py":      {"*.py", "*.pyw"},
+	"rust":    {"*.rs"},
+	"sh":      {"*.sh", "*.bash", "*.zsh"},
+	"text":    {"*.txt", "*.text", "*.md", "*.rst"},
+	"xml":     {"*.xml", "*.xsd", "*.xsl"},
+}
+
+// the types given by -type
+var typeNames typeList
+
+//// TYPE typeList ////
+
+// A flag.Value that collects the comma-separated type names of each use of
+// -type.
+type typeList []string
+
+func (l *typeList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *typeList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+//// TYPE typeAdder ////
+
+// A flag.Value that adds patterns to a file type, given as
+// name:pattern[,pattern...], each time the flag is given.
+type typeAdder struct{}
+
+func (typeAdder) String() string {
+	return ""
+}
+
+func (typeAdder) Set(value string) error {
+	colon := strings.Index(value, ":")
+	if colon <= 0 || colon == len(value)-1 {
+		return fmt.Errorf("%q is not of the form name:pattern[,pattern...]", value)
+	}
+	name := value[:colon]
+	for _, pattern := range strings.Split(value[colon+1:], ",") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+		fileTypes[name] = append(fileTypes[name], pattern)
+	}
+	return nil
+}
+
+//// FUNCTIONS ////
+
+// Checks that every type given by -type is defined. Exits if one is not.
+func checkTypes() {
+	for _, name := range typeNames {
+		if _, defined := fileTypes[name]; !defined {
+			myerr.MyImmediateFatal(status_fatal_error, "error: unknown file type %q; -type-list lists them", name)
+		}
+	}
+}
+
+// Is a file of the given name of one of the types given by -type? Every
+// file is when none were given.
+func typeMatches(name string) bool {
+	if len(typeNames) == 0 {
+		return true
+	}
+	for _, t := range typeNames {
+		for _, pattern := range fileTypes[t] {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Writes each file type and its patterns to w, one per line.
+func listTypes(w io.Writer) {
+	names := make([]string, 0, len(fileTypes))
+	for name := range fileTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(fileTypes[name], ", "))
+	}
+}
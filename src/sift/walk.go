@@ -0,0 +1,196 @@
+/*
+This file implements the filters sift applies while descending
+directories, and the reading of lists of inputs (-files-from).
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//// TYPE stringList ////
+
+// A flag.Value that collects the value of each use of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	if _, err := filepath.Match(value, ""); err != nil {
+		return err
+	}
+	*l = append(*l, value)
+	return nil
+}
+
+//// TYPE timeFlag ////
+
+// the layouts, besides RFC 3339, accepted for timestamps by a timeFlag;
+// they are taken to be in local time
+var time_layouts = []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// A flag.Value holding a point in time, given either as a timestamp or as a
+// duration (e.g., 36h) before the present.
+type timeFlag struct {
+	time.Time
+}
+
+func (t *timeFlag) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(value string) error {
+	if d, err := time.ParseDuration(value); err == nil {
+		t.Time = time.Now().Add(-d)
+		return nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		t.Time = parsed
+		return nil
+	}
+	for _, layout := range time_layouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is neither a duration (e.g., 36h) nor a timestamp (e.g., 2012-06-30 or 2012-06-30T18:00:00)", value)
+}
+
+//// FUNCTIONS ////
+
+// the directory names or patterns given by -exclude-dir
+var excludeDirs stringList
+
+// Is the file or directory of the given name hidden, and so skipped during
+// recursive descent unless -hidden is given?
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".") && !*includeHidden
+}
+
+// Should a directory of the given name be skipped during recursive
+// descent? It is if the name matches any -exclude-dir pattern.
+func excludedDir(name string) bool {
+	for _, pattern := range excludeDirs {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Should the entries of a directory at depth be visited? Not if -max-depth
+// would put them too deep.
+func descendInto(depth int) bool {
+	return *maxDepth < 0 || depth < *maxDepth
+}
+
+// with -L, the real paths of the directories descended during the current
+// search
+var visitedDirs map[string]bool
+
+// Should the directory at path be descended? With -L, not if it already
+// was, whether through a symbolic link or not; this also ends loops through
+// circular links.
+func firstVisit(path string) bool {
+	if !*followSymbolicLinks {
+		return true
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		real, err = filepath.Abs(real)
+	}
+	if err != nil {
+		return true
+	}
+	if visitedDirs[real] {
+		return false
+	}
+	visitedDirs[real] = true
+	return true
+}
+
+// Should a file at depth be searched? Not if it is shallower than
+// -min-depth.
+func deepEnough(depth int) bool {
+	return depth >= *minDepth
+}
+
+// the bounds given by -newer-than and -older-than
+var newerThan, olderThan timeFlag
+
+// Should a file with the given information be searched? Not if it was
+// modified outside the bounds given by -newer-than and -older-than.
+func modifiedInRange(info os.FileInfo) bool {
+	modified := info.ModTime()
+	if !newerThan.IsZero() && !modified.After(newerThan.Time) {
+		return false
+	}
+	return olderThan.IsZero() || modified.Before(olderThan.Time)
+}
+
+// Returns what keeps the file entry, with the given information, found at
+// depth from being searched: the flag whose bounds it falls outside, or ""
+// if none does.
+func filteredBy(entry string, info os.FileInfo, depth int) string {
+	switch {
+	case !deepEnough(depth):
+		return "-min-depth"
+	case !modifiedInRange(info):
+		return "-newer-than or -older-than"
+	case depth > 0 && !typeMatches(entry):
+		return "-type"
+	}
+	return ""
+}
+
+// Calls fn with each path listed in the file named name ("-" being the
+// standard input), one per line or, if nul is true, terminated by NUL
+// bytes. Empty entries are ignored.
+func readFileList(name string, nul bool, fn func(path string)) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	delim := byte('\n')
+	if nul {
+		delim = 0
+	}
+	in := bufio.NewReader(r)
+	for {
+		entry, err := in.ReadString(delim)
+		entry = strings.TrimSuffix(entry, string(delim))
+		if !nul {
+			entry = strings.TrimSuffix(entry, "\r")
+		}
+		if entry != "" {
+			fn(entry)
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+This file implements searching several files at once (the -j flag). Each
+file's results are collected in memory and written once the results of
+every file found before it have been, so the output is the same as that of
+a sequential search.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// A file waiting to be searched, or whose results are waiting to be
+// written.
+type job struct {
+	path     string
+	size     int64
+	from     int64              // the offset from which the file is searched
+	reported int64              // matches ending at or before this offset are not reported (see followFrom)
+	done     chan *bytes.Buffer // receives the results once the file is searched
+}
+
+var (
+	jobs    chan *job      // files waiting for a worker
+	pending chan *job      // files in the order found, waiting to be written
+	written chan bool      // closed once every file's results are written
+	working sync.WaitGroup // the workers
+)
+
+// Starts the workers if -j asks for more than one.
+func startWorkers() {
+	if *workers <= 1 {
+		return
+	}
+
+	jobs = make(chan *job)
+	pending = make(chan *job, 4**workers)
+	written = make(chan bool)
+
+	for i := 0; i < *workers; i++ {
+		working.Add(1)
+		go func() {
+			defer working.Done()
+			for j := range jobs {
+				var buf bytes.Buffer
+				processFile(j.path, j.from, j.reported, &buf)
+				noteFileDone(j.size)
+				j.done <- &buf
+			}
+		}()
+	}
+
+	go func() {
+		defer close(written)
+		for j := range pending {
+			(<-j.done).WriteTo(output)
+			if j.path != "" {
+				noteCompleted(j.path)
+			}
+		}
+	}()
+}
+
+// Searches the file at path, of size bytes, now or, if there are workers,
+// hands it to one. In follow mode, only what has changed since it was last
+// searched is searched, if anything.
+func scheduleFile(path string, size int64) {
+	if alreadyCompleted(path) {
+		return
+	}
+	from, reported, search := followFrom(path, size)
+	if !search {
+		return
+	}
+
+	noteFileFound(size)
+	if jobs == nil {
+		processFile(path, from, reported, output)
+		noteFileDone(size)
+		noteCompleted(path)
+		return
+	}
+
+	j := &job{path, size, from, reported, make(chan *bytes.Buffer, 1)}
+	pending <- j
+	jobs <- j
+}
+
+// Writes text to output after the results of every file scheduled so far.
+func writeInOrder(text string) {
+	if jobs == nil {
+		io.WriteString(output, text)
+		return
+	}
+
+	j := &job{done: make(chan *bytes.Buffer, 1)}
+	j.done <- bytes.NewBufferString(text)
+	pending <- j
+}
+
+// Waits for the workers, if any, to search every file scheduled and for
+// the results to be written.
+func finishWorkers() {
+	if jobs == nil {
+		return
+	}
+
+	close(jobs)
+	working.Wait()
+	close(pending)
+	<-written
+}
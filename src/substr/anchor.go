@@ -0,0 +1,129 @@
+/*
+This file implements anchored searches, which only accept matches at the
+very start of the haystack or ending exactly at its end (see
+WithAnchorStart and WithAnchorEnd). Only the bytes that could hold such a
+match are examined, where the haystack allows it.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// Sends the anchored matches of the needles of set within haystack to
+// emit, ordered by offset and then by needle.
+func searchAnchored(haystack Haystack, set *NeedleSet, cfg *config, emit func(Result) bool) {
+	var window []byte
+	var base uint64
+	var err error
+	if cfg.anchorStart {
+		// when also anchored at the end, one extra byte shows whether the
+		// data goes on past the longest needle
+		head := set.maxLen
+		if cfg.anchorEnd {
+			head++
+		}
+		window, err = readHead(haystack, head)
+	} else {
+		window, base, err = readTail(haystack, set.maxLen)
+	}
+	if err != nil {
+		emit(Result{Offset: errorOffset, Error: err})
+		return
+	}
+	cfg.transform(window, base)
+	cfg.scanned(len(window))
+
+	results := make([]Result, 0)
+	for i, needle := range set.needles {
+		length := int(needle.length)
+		index := 0
+		if cfg.anchorEnd {
+			index = len(window) - length
+		}
+		if index < 0 || (cfg.anchorStart && index != 0) || index+length > len(window) {
+			continue
+		}
+		if bytes.Equal(window[index:index+length], needle.bytes) {
+			results = append(results, Result{base + uint64(index), nil, i})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Offset < results[j].Offset
+	})
+	emitAll(results, emit)
+}
+
+// Returns up to the first n bytes of haystack.
+func readHead(haystack Haystack, n uint32) ([]byte, error) {
+	if b, ok := haystack.(bytesHaystack); ok {
+		if len(b) > int(n) {
+			b = b[:n]
+		}
+		return append([]byte(nil), b...), nil
+	}
+
+	r, err := haystack.Reader()
+	if err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, n)
+	count, err := io.ReadFull(r, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return buffer[:count], err
+}
+
+// Returns up to the last n bytes of haystack and their offset. Haystacks
+// of known size that allow random access are read only at the end; others
+// are read through.
+func readTail(haystack Haystack, n uint32) ([]byte, uint64, error) {
+	if ra, ok := haystack.(randomAccessHaystack); ok && ra.Size() >= 0 {
+		size := ra.Size()
+		start := size - int64(n)
+		if start < 0 {
+			start = 0
+		}
+		buffer := make([]byte, size-start)
+		count, err := ra.ReaderAt().ReadAt(buffer, start)
+		if err == io.EOF && count == len(buffer) {
+			err = nil
+		}
+		return buffer[:count], uint64(start), err
+	}
+
+	r, err := haystack.Reader()
+	if err != nil {
+		return nil, 0, err
+	}
+	keep := int(n)
+	buffer := make([]byte, buffSize+keep)
+	used := 0
+	offset := uint64(0) // offset within haystack of buffer[0]
+	for {
+		count, err := io.ReadFull(r, buffer[used:])
+		used += count
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		copy(buffer[0:], buffer[used-keep:used])
+		offset += uint64(used - keep)
+		used = keep
+	}
+
+	if used > keep {
+		offset += uint64(used - keep)
+		return buffer[used-keep : used], offset, nil
+	}
+	return buffer[:used], offset, nil
+}
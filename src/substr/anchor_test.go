@@ -0,0 +1,48 @@
+/*
+This file includes tests for the anchored searches of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestAnchorStart(t *testing.T) {
+	expect1(t, Indexes(NewHaystackStr("PK..PK.."), NewNeedleStr("PK"), WithAnchorStart()), 0, "match")
+	expect0(t, Indexes(NewHaystackStr(".PK..PK."), NewNeedleStr("PK"), WithAnchorStart()), "no match")
+	expect0(t, Indexes(NewHaystackStr("P"), NewNeedleStr("PK"), WithAnchorStart()), "short")
+
+	data := prepPlaced(3*buffSize, "PK", []uint64{0, 100})
+	r := bytes.NewReader(data)
+	expect1(t, Indexes(NewHaystackReader(r), NewNeedleStr("PK"), WithAnchorStart()), 0, "reader")
+	if r.Len() != len(data)-2 {
+		t.Error(fmt.Sprintf("expected only 2 bytes to be read, %d were", len(data)-r.Len()))
+	}
+}
+
+func TestAnchorEnd(t *testing.T) {
+	data := prepPlaced(3*buffSize+5, "EOF", []uint64{7, 3*buffSize + 2})
+	expect1(t, Indexes(NewHaystackBytes(data), NewNeedleStr("EOF"), WithAnchorEnd()), 3*buffSize+2, "bytes")
+	expect1(t, Indexes(NewHaystackReader(bytes.NewReader(data)), NewNeedleStr("EOF"), WithAnchorEnd()), 3*buffSize+2, "reader")
+	expect0(t, Indexes(NewHaystackStr("EOF."), NewNeedleStr("EOF"), WithAnchorEnd()), "no match")
+
+	set := NewNeedleSet(NewNeedleStr("F"), NewNeedleStr("EOF"), NewNeedleStr("xEOF"))
+	results, err := convert(IndexesSet(NewHaystackStr("..EOF"), set, WithAnchorEnd()))
+	if err != nil || fmt.Sprint(results) != "[2 4]" {
+		t.Error(fmt.Sprintf("unexpected results %v %v", results, err))
+	}
+}
+
+func TestAnchorBoth(t *testing.T) {
+	expect1(t, Indexes(NewHaystackStr("magic"), NewNeedleStr("magic"), WithAnchorStart(), WithAnchorEnd()), 0, "whole")
+	expect0(t, Indexes(NewHaystackStr("magic!"), NewNeedleStr("magic"), WithAnchorStart(), WithAnchorEnd()), "longer")
+	r := bytes.NewReader([]byte("magic!"))
+	expect0(t, Indexes(NewHaystackReader(r), NewNeedleStr("magic"), WithAnchorStart(), WithAnchorEnd()), "longer reader")
+}
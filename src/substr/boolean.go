@@ -0,0 +1,169 @@
+/*
+This file implements boolean combinations of needles (e.g., "contains X and
+Y but not Z"), evaluated during a single pass over the haystack.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+// A boolean combination of needles. An expression is built with Has, And,
+// Or, and Not, and evaluated against a haystack with Evaluate.
+type Expr interface {
+	// Adds the expression's needles to needles, if not already present.
+	collect(needles *[]*Needle)
+
+	// Evaluates the expression given which of needles have been found so
+	// far, treating those not yet found as absent. known is false if the
+	// value could still change as more of the haystack is searched.
+	eval(needles []*Needle, found []bool) (value, known bool)
+}
+
+// The outcome of evaluating an expression. Witnesses holds the first match
+// of each needle that was found; its Pattern is the needle's index within
+// Needles, the expression's needles in the order they first appear in it.
+type Verdict struct {
+	Matched   bool
+	Needles   []*Needle
+	Witnesses []Result
+}
+
+type hasExpr struct {
+	needle *Needle
+}
+
+type andExpr []Expr
+
+type orExpr []Expr
+
+type notExpr struct {
+	expr Expr
+}
+
+// Returns an expression that is true if needle occurs in the haystack.
+func Has(needle *Needle) Expr {
+	return &hasExpr{needle}
+}
+
+// Returns an expression that is true if all of exprs are.
+func And(exprs ...Expr) Expr {
+	return andExpr(exprs)
+}
+
+// Returns an expression that is true if any of exprs is.
+func Or(exprs ...Expr) Expr {
+	return orExpr(exprs)
+}
+
+// Returns an expression that is true if expr is not.
+func Not(expr Expr) Expr {
+	return &notExpr{expr}
+}
+
+func (e *hasExpr) collect(needles *[]*Needle) {
+	if indexOfNeedle(*needles, e.needle) < 0 {
+		*needles = append(*needles, e.needle)
+	}
+}
+
+func (e *hasExpr) eval(needles []*Needle, found []bool) (value, known bool) {
+	if found[indexOfNeedle(needles, e.needle)] {
+		return true, true
+	}
+	return false, false
+}
+
+func (e andExpr) collect(needles *[]*Needle) {
+	for _, sub := range e {
+		sub.collect(needles)
+	}
+}
+
+func (e andExpr) eval(needles []*Needle, found []bool) (value, known bool) {
+	value, known = true, true
+	for _, sub := range e {
+		v, k := sub.eval(needles, found)
+		if k && !v {
+			return false, true
+		}
+		value, known = value && v, known && k
+	}
+	return
+}
+
+func (e orExpr) collect(needles *[]*Needle) {
+	for _, sub := range e {
+		sub.collect(needles)
+	}
+}
+
+func (e orExpr) eval(needles []*Needle, found []bool) (value, known bool) {
+	value, known = false, true
+	for _, sub := range e {
+		v, k := sub.eval(needles, found)
+		if k && v {
+			return true, true
+		}
+		value, known = value || v, known && k
+	}
+	return
+}
+
+func (e *notExpr) collect(needles *[]*Needle) {
+	e.expr.collect(needles)
+}
+
+func (e *notExpr) eval(needles []*Needle, found []bool) (value, known bool) {
+	value, known = e.expr.eval(needles, found)
+	return !value, known
+}
+
+// Evaluates expr against haystack, searching for all of its needles in one
+// pass. The search stops as soon as the outcome can no longer change (e.g.,
+// once both needles of an And have been found).
+func Evaluate(haystack Haystack, expr Expr, opts ...Option) (verdict Verdict, e error) {
+	expr.collect(&verdict.Needles)
+	found := make([]bool, len(verdict.Needles))
+
+	value, known := expr.eval(verdict.Needles, found)
+	if known || len(verdict.Needles) == 0 {
+		verdict.Matched = value
+		return
+	}
+
+	// the scan ends once the value is known, and not before: a limit on the
+	// matches would cut it short and leave the verdict wrong
+	cfg := orderedConfig(opts)
+	cfg.limit = 0
+	run(haystack, NewNeedleSet(verdict.Needles...), cfg, func(r Result) bool {
+		if r.Error != nil {
+			e = r.Error
+			return false
+		}
+		if found[r.Pattern] {
+			return true
+		}
+		found[r.Pattern] = true
+		verdict.Witnesses = append(verdict.Witnesses, r)
+		value, known = expr.eval(verdict.Needles, found)
+		return !known
+	})
+
+	// whatever was not found by the end cannot be, so the value now stands
+	verdict.Matched = value
+	if e != nil {
+		verdict.Matched = false
+	}
+	return
+}
+
+// Returns the index of needle within needles, or -1.
+func indexOfNeedle(needles []*Needle, needle *Needle) int {
+	for i, n := range needles {
+		if n == needle {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,70 @@
+/*
+This file includes tests for the boolean needle expressions of the substr
+package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	x, y, z := NewNeedleStr("X"), NewNeedleStr("Y"), NewNeedleStr("Z")
+	tests := []struct {
+		haystack string
+		expr     Expr
+		expect   bool
+	}{
+		{"..X..Y..", And(Has(x), Has(y), Not(Has(z))), true},
+		{"..X..Y.Z", And(Has(x), Has(y), Not(Has(z))), false},
+		{"..X.....", And(Has(x), Has(y)), false},
+		{"......Y.", Or(Has(x), Has(y)), true},
+		{"........", Or(Has(x), Has(y)), false},
+		{"........", Not(Has(z)), true},
+		{"..Z.....", Or(Not(Has(z)), Has(x)), false},
+	}
+	for i, test := range tests {
+		verdict, err := Evaluate(NewHaystackStr(test.haystack), test.expr)
+		if err != nil {
+			t.Error(fmt.Sprintf("got unexpected error %s (note: %d)", err, i))
+		}
+		if verdict.Matched != test.expect {
+			t.Error(fmt.Sprintf("expected %v got %v (note: %d)", test.expect, verdict.Matched, i))
+		}
+	}
+}
+
+func TestEvaluateWitnesses(t *testing.T) {
+	x, y := NewNeedleStr("X"), NewNeedleStr("Y")
+	verdict, err := Evaluate(NewHaystackStr("..Y..X..X..Y"), And(Has(x), Has(y), Has(x)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verdict.Matched || len(verdict.Needles) != 2 {
+		t.Fatal(fmt.Sprintf("unexpected verdict %v", verdict))
+	}
+	got := fmt.Sprint(verdict.Witnesses)
+	if got != "[Result{2, <nil>} Result{5, <nil>}]" || verdict.Witnesses[0].Pattern != 1 {
+		t.Error(fmt.Sprintf("unexpected witnesses %s", got))
+	}
+}
+
+func TestEvaluateIgnoresLimit(t *testing.T) {
+	x, y := NewNeedleStr("X"), NewNeedleStr("Y")
+	haystack := "..X..X..Y.."
+	for _, opts := range [][]Option{{WithLimit(1)}, {WithUnordered(), WithParallel(4), WithLimit(1)}} {
+		verdict, err := Evaluate(NewHaystackStr(haystack), And(Has(x), Has(y)), opts...)
+		if err != nil {
+			t.Error(fmt.Sprintf("got unexpected error %s", err))
+		}
+		if !verdict.Matched || len(verdict.Witnesses) != 2 || verdict.Witnesses[0].Offset != 2 || verdict.Witnesses[1].Offset != 8 {
+			t.Error(fmt.Sprintf("expected a match witnessed at 2 and 8, got %v %v", verdict.Matched, verdict.Witnesses))
+		}
+	}
+}
@@ -24,10 +24,13 @@ const (
 	errorOffset = math.MaxUint32
 )
 
+// the most of an in-memory haystack scanned as one window, since offsets
+// within a window are 32 bits; a variable so that tests can make it small
+var bytesWindowSize = 1 << 30
+
 // The error returned if an empty needle is provided to one of the search functions.
 var ErrEmptyNeedle = errors.New("boyer_moore: the needle may not be empty")
 
-
 // A processed version of the needle in which various tables have been
 // created that make the searching efficient (via Boyer-Moore algorithm).
 // If one is searching multiple blocks of data, it's better to calculate
@@ -56,125 +59,81 @@ func NewNeedleStr(needle string) *Needle {
 
 // A result from a search. It either contains an error, if Error is not nil.
 // If Error is nil, then Offset contains the offset of a match within the
-// data searched.
+// data searched, and Pattern the index within the NeedleSet of the needle
+// that matched (always 0 when searching for a single needle).
 type Result struct {
-	Offset uint32
-	Error  error
+	Offset  uint64
+	Error   error
+	Pattern int
 }
 
 // Returns a string version of a Result, which can be used in testing.
-func (r *Result) string() string {
+func (r Result) String() string {
 	return fmt.Sprintf("Result{%v, %v}", r.Offset, r.Error)
 }
 
 // Searches for needle within haystack. Returns any=true if any match is
 // found; firstOffset is location of first match; and e is any error that
 // occurred.
-func IndexWithinReaderStr(haystack io.Reader, needle string) (any bool, firstOffset uint32, e error) {
-	return IndexWithinReaderNeedle(haystack, NewNeedleStr(needle))
+func Index(haystack Haystack, needle *Needle, opts ...Option) (any bool, firstOffset uint64, e error) {
+	return returnOne(search(haystack, NewNeedleSet(needle), true, opts))
+}
+
+// Searches for all matches of needle within haystack. The results are sent
+// in ascending offset order (unless WithUnordered is given) on the channel
+// returned, which is closed when the search is complete.
+func Indexes(haystack Haystack, needle *Needle, opts ...Option) <-chan Result {
+	return search(haystack, NewNeedleSet(needle), false, opts)
+}
+
+// Searches for needle within haystack. Returns any=true if any match is
+// found; firstOffset is location of first match; and e is any error that
+// occurred.
+func IndexWithinReaderStr(haystack io.Reader, needle string) (any bool, firstOffset uint64, e error) {
+	return Index(NewHaystackReader(haystack), NewNeedleStr(needle))
 }
 
 // Searches for needle within haystack. Returns any=true if any match is
 // found; firstOffset is location of first match; and e is any error that
 // occurred.
 func IndexesWithinReaderStr(haystack io.Reader, needle string) <-chan Result {
-	return IndexesWithinReaderNeedle(haystack, NewNeedleStr(needle))
+	return Indexes(NewHaystackReader(haystack), NewNeedleStr(needle))
 }
 
 // Searches for needle within haystack. Returns any=true if any match is
 // found; firstOffset is location of first match; and e is any error that
 // occurred.
-func IndexWithinReaderBytes(haystack io.Reader, needle []byte) (any bool, firstOffset uint32, e error) {
-	return IndexWithinReaderNeedle(haystack, NewNeedleBytes(needle))
+func IndexWithinReaderBytes(haystack io.Reader, needle []byte) (any bool, firstOffset uint64, e error) {
+	return Index(NewHaystackReader(haystack), NewNeedleBytes(needle))
 }
 
 // Searches for needle within haystack. Returns any=true if any match is
 // found; firstOffset is location of first match; and e is any error that
 // occurred.
 func IndexesWithinReaderBytes(haystack io.Reader, needle []byte) <-chan Result {
-	return IndexesWithinReaderNeedle(haystack, NewNeedleBytes(needle))
+	return Indexes(NewHaystackReader(haystack), NewNeedleBytes(needle))
 }
 
 // Searches for needle within haystack. Returns any=true if any match is
 // found; firstOffset is location of first match; and e is any error that
 // occurred.
-func IndexWithinReaderNeedle(haystack io.Reader, needle *Needle) (any bool, firstOffset uint32, e error) {
-	return returnOne(indexesWithinReaderHelp(haystack, needle, true))
+func IndexWithinReaderNeedle(haystack io.Reader, needle *Needle) (any bool, firstOffset uint64, e error) {
+	return Index(NewHaystackReader(haystack), needle)
 }
 
 // Searches for needle within haystack. Returns any=true if any match is
 // found; firstOffset is location of first match; and e is any error that
 // occurred.
 func IndexesWithinReaderNeedle(haystack io.Reader, needle *Needle) <-chan Result {
-	return indexesWithinReaderHelp(haystack, needle, false)
-}
-
-// Searches for needle within haystack. stopAtFirst determines whether
-// it keeps searching once a match is found. The results are sent on
-// the channel returned.
-func indexesWithinReaderHelp(haystack io.Reader, needle *Needle, stopAtFirst bool) <-chan Result {
-	out := make(chan Result, outChanSize)
-
-	go func() {
-		if needle.length == 0 {
-			out <- Result{errorOffset, ErrEmptyNeedle}
-			close(out)
-		}
-
-		offset := uint32(0)
-		var buffer [buffSize]byte
-		used := uint32(0)
-		done := false
-
-	outer:
-		for {
-			count, err := haystack.Read(buffer[used:])
-			if count > 0 {
-				used += uint32(count)
-				if used < buffSize {
-					continue
-				}
-			} else if err != io.EOF {
-				out <- Result{errorOffset, err}
-				break outer
-			} else {
-				done = true
-			}
-
-			haystackSkip := uint32(0)
-			for {
-				index := indexOfHelper(buffer[0:used], needle, used, haystackSkip)
-				if index == errorOffset {
-					break
-				}
-				out <- Result{offset + index, nil}
-				if stopAtFirst {
-					break outer
-				}
-				haystackSkip = index + 1
-			}
-
-			if done {
-				break
-			}
-
-			copy(buffer[0:], buffer[used-needle.length+1:used])
-			offset += used - needle.length - 1
-			used = needle.length - 1
-		}
-
-		close(out)
-	}()
-
-	return out
+	return Indexes(NewHaystackReader(haystack), needle)
 }
 
 /*
 Returns the index of the first match of needle within haystack.
 If no matches are found, returns -1. Parameter needle must not be empty.
 */
-func IndexOfStr(haystack, needle string) (any bool, firstOffset uint32, e error) {
-	return IndexOf([]byte(haystack), []byte(needle))
+func IndexOfStr(haystack, needle string) (any bool, firstOffset uint64, e error) {
+	return Index(NewHaystackStr(haystack), NewNeedleStr(needle))
 }
 
 /*
@@ -183,60 +142,220 @@ If no matches are found returns a slice of size 0.
 Parameter needle must not be empty.
 */
 func IndexesOfStr(haystack, needle string) <-chan Result {
-	return IndexesOf([]byte(haystack), []byte(needle))
+	return Indexes(NewHaystackStr(haystack), NewNeedleStr(needle))
 }
 
 // Returns the index of the first match of needle within haystack.
 // If no matches are found, returns -1. Parameter needle must not be empty.
-func IndexOf(haystack, needleBytes []byte) (any bool, firstOffset uint32, e error) {
+func IndexOf(haystack, needleBytes []byte) (any bool, firstOffset uint64, e error) {
+	return Index(NewHaystackBytes(haystack), NewNeedleBytes(needleBytes))
+}
+
+// Returns the indexes of all matches of needle within haystack.
+// If no matches are found returns a slice of size 0.
+// Parameter needle must not be empty.
+func IndexesOf(haystack, needleBytes []byte) <-chan Result {
+	return Indexes(NewHaystackBytes(haystack), NewNeedleBytes(needleBytes))
+}
+
+// Searches for the needles of set within haystack. stopAtFirst determines
+// whether it keeps searching once a match is found. The results are sent
+// on the channel returned.
+func search(haystack Haystack, set *NeedleSet, stopAtFirst bool, opts []Option) <-chan Result {
+	cfg := newConfig(opts)
 	out := make(chan Result, outChanSize)
 
 	go func() {
-		needle := NewNeedleBytes(needleBytes)
-		if needle.length == 0 {
-			out <- Result{errorOffset, ErrEmptyNeedle}
-		}
+		defer close(out)
+		run(haystack, set, cfg, func(r Result) bool {
+			out <- r
+			return r.Error == nil && !stopAtFirst
+		})
+	}()
+
+	return out
+}
 
-		haystackLen := uint32(len(haystack))
+// Searches for the needles of set within haystack, sending each match (in
+// ascending offset order, unless the configuration allows otherwise) to
+// emit until emit returns false. An error is sent to emit as the final
+// Result.
+func run(haystack Haystack, set *NeedleSet, cfg *config, emit func(Result) bool) {
+	cfg.startStats()
+	defer cfg.finishStats()
+
+	if set.empty() {
+		emit(Result{Offset: errorOffset, Error: ErrEmptyNeedle})
+		return
+	}
 
-		index := indexOfHelper(haystack, needle, haystackLen, 0)
-		if index != errorOffset {
-			out <- Result{index, nil}
+	count := uint64(0)
+	report := func(r Result) bool {
+		if r.Error != nil {
+			emit(r)
+			return false
 		}
-		close(out)
-	}()
+		r.Offset += cfg.baseOffset
+		cfg.matched()
+		count++
+		return emit(r) && !cfg.cancelled() && (cfg.limit == 0 || count < cfg.limit)
+	}
 
-	return returnOne(out)
+	if cfg.anchorStart || cfg.anchorEnd {
+		searchAnchored(haystack, set, cfg, report)
+		return
+	}
+
+	if ra, ok := haystack.(randomAccessHaystack); ok && cfg.stride > 0 && ra.Size() >= 0 {
+		searchSampled(ra, set, cfg, report)
+		return
+	}
+
+	if ra, ok := haystack.(randomAccessHaystack); ok && cfg.workers > 1 && ra.Size() >= 0 {
+		searchParallel(ra, set, cfg, report)
+		return
+	}
+
+	if b, ok := haystack.(bytesHaystack); ok && len(cfg.transforms) == 0 {
+		searchBytes(b, set, cfg, report)
+		return
+	}
+
+	r, err := haystack.Reader()
+	if err != nil {
+		report(Result{Offset: errorOffset, Error: err})
+		return
+	}
+	searchReader(r, set, cfg, report)
 }
 
-// Returns the indexes of all matches of needle within haystack.
-// If no matches are found returns a slice of size 0.
-// Parameter needle must not be empty.
-func IndexesOf(haystack, needleBytes []byte) <-chan Result {
-	out := make(chan Result, 64)
+// Sends each match within the in-memory haystack to emit, until emit
+// returns false. The haystack is searched a window of at most
+// bytesWindowSize bytes at a time; the end of each window that could hold
+// the start of a match is searched again as the start of the next.
+func searchBytes(haystack []byte, set *NeedleSet, cfg *config, emit func(Result) bool) {
+	cfg.scanned(len(haystack))
+	keep := int(set.maxLen) - 1
+	size := bytesWindowSize
+	if size < 2*int(set.maxLen) {
+		size = 2 * int(set.maxLen)
+	}
 
-	go func() {
-		needle := NewNeedleBytes(needleBytes)
-		if needle.length == 0 {
-			out <- Result{errorOffset, ErrEmptyNeedle}
+	start := 0
+	for len(haystack)-start > size {
+		if cfg.cancelled() {
+			return
+		}
+		limit := size - keep
+		if !scanWindow(haystack[start:start+size], set, uint32(limit), uint64(start), cfg, emit) {
+			return
+		}
+		start += limit
+	}
+	if !cfg.cancelled() {
+		scanWindow(haystack[start:], set, uint32(len(haystack)-start), uint64(start), cfg, emit)
+	}
+}
+
+// Sends each match within the data read from haystack to emit, until emit
+// returns false. The data is searched a buffer at a time; the end of each
+// buffer that could hold the start of a match is carried over to the
+// beginning of the next.
+func searchReader(haystack io.Reader, set *NeedleSet, cfg *config, emit func(Result) bool) {
+	keep := set.maxLen - 1
+	size := uint32(buffSize)
+	if size < 2*set.maxLen {
+		size = 2 * set.maxLen
+	}
+	buffer := make([]byte, size)
+	offset := uint64(0) // offset within haystack of buffer[0]
+	used := uint32(0)
+
+	for !cfg.cancelled() {
+		count, err := io.ReadFull(haystack, buffer[used:])
+		cfg.transform(buffer[used:used+uint32(count)], offset+uint64(used))
+		cfg.scanned(count)
+		used += uint32(count)
+		done := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !done {
+			emit(Result{Offset: errorOffset, Error: err})
+			return
 		}
 
-		haystackLen := uint32(len(haystack))
-		var haystackStartingIndex uint32 = 0
+		// matches beginning in the part carried over are found in the next
+		// window, which keeps the results of different needles in order
+		limit := used
+		if !done {
+			limit -= keep
+		}
+		if !scanWindow(buffer[0:used], set, limit, offset, cfg, emit) || done {
+			return
+		}
 
-		for {
-			index := indexOfHelper(haystack, needle, haystackLen, haystackStartingIndex)
-			if index == errorOffset {
-				break
+		copy(buffer[0:], buffer[used-keep:used])
+		offset += uint64(used - keep)
+		used = keep
+	}
+}
+
+// Sends each match that begins before limit within window to emit, in
+// ascending order, adding base to each index to give its offset. Returns
+// false if emit did, signaling that the search should stop.
+func scanWindow(window []byte, set *NeedleSet, limit uint32, base uint64, cfg *config, emit func(Result) bool) bool {
+	windowLen := uint32(len(window))
+	next := make([]uint32, len(set.needles)) // next index of each needle, or errorOffset
+	var found [][]uint32
+	if cfg.crossCheck {
+		found = make([][]uint32, len(set.needles))
+	}
+
+	for i, needle := range set.needles {
+		next[i] = nextIndex(window, needle, windowLen, 0, limit)
+	}
+
+	for {
+		best := -1
+		for i, index := range next {
+			if index != errorOffset && (best < 0 || index < next[best]) {
+				best = i
 			}
-			out <- Result{index, nil}
-			haystackStartingIndex = index + 1
+		}
+		if best < 0 {
+			break
 		}
 
-		close(out)
-	}()
+		index := next[best]
+		if cfg.crossCheck {
+			found[best] = append(found[best], index)
+		}
+		if !emit(Result{base + uint64(index), nil, best}) {
+			if cfg.crossCheck {
+				if err := crossCheckSet(window, set, limit, base, found, false); err != nil {
+					emit(Result{Offset: errorOffset, Error: err})
+				}
+			}
+			return false
+		}
+		next[best] = nextIndex(window, set.needles[best], windowLen, index+1, limit)
+	}
 
-	return out
+	if cfg.crossCheck {
+		if err := crossCheckSet(window, set, limit, base, found, true); err != nil {
+			emit(Result{Offset: errorOffset, Error: err})
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the index of the next match of needle within window at or after
+// haystackSkip, or errorOffset if there is none that begins before limit.
+func nextIndex(window []byte, needle *Needle, windowLen, haystackSkip, limit uint32) uint32 {
+	index := indexOfHelper(window, needle, windowLen, haystackSkip)
+	if index >= limit {
+		return errorOffset
+	}
+	return index
 }
 
 // Returns the next found index of needle within haystack after skipping
@@ -309,10 +428,9 @@ func suffixLength(needle []byte, p int) int {
 	return length
 }
 
-
 // Given a channel of Result/s returns the first Result and insures that no
 // more are returned (if there is another result, it panics).
-func returnOne(c <-chan Result) (any bool, firstOffset uint32, e error) {
+func returnOne(c <-chan Result) (any bool, firstOffset uint64, e error) {
 	if result, ok := <-c; ok {
 		if result.Error == nil {
 			if _, ok = <-c; ok {
@@ -17,8 +17,8 @@ import (
 )
 
 // convert a channel of results into an array of offsets plus any error
-func convert(in <-chan Result) ([]uint32, error) {
-	results := make([]uint32, 0)
+func convert(in <-chan Result) ([]uint64, error) {
+	results := make([]uint64, 0)
 	var e error
 
 	for r := range in {
@@ -41,7 +41,7 @@ func expect0(t *testing.T, in <-chan Result, notation interface{}) {
 }
 
 // expect 1 result to come in through a channel
-func expect1(t *testing.T, in <-chan Result, value uint32, notation interface{}) {
+func expect1(t *testing.T, in <-chan Result, value uint64, notation interface{}) {
 	var r Result
 	var ok bool
 
@@ -64,7 +64,7 @@ func expect1(t *testing.T, in <-chan Result, value uint32, notation interface{})
 	}
 }
 
-func got1(t *testing.T, found bool, offset uint32, err error, expectedOffset uint32, notation interface{}) {
+func got1(t *testing.T, found bool, offset uint64, err error, expectedOffset uint64, notation interface{}) {
 	if !found {
 		t.Error(fmt.Sprintf("got 0 matches, expected 1 (note: %v)", notation))
 	} else if offset != expectedOffset {
@@ -75,7 +75,7 @@ func got1(t *testing.T, found bool, offset uint32, err error, expectedOffset uin
 	}
 }
 
-func got0(t *testing.T, found bool, offset uint32, err error, notation interface{}) {
+func got0(t *testing.T, found bool, offset uint64, err error, notation interface{}) {
 	if found {
 		t.Error(fmt.Sprintf("got a match (%d), expected none (note: %v)", offset, notation))
 	}
@@ -84,7 +84,7 @@ func got0(t *testing.T, found bool, offset uint32, err error, notation interface
 	}
 }
 
-func gotError(t *testing.T, found bool, offset uint32, err error, expectedError error, notation interface{}) {
+func gotError(t *testing.T, found bool, offset uint64, err error, expectedError error, notation interface{}) {
 	if found {
 		t.Error(fmt.Sprintf("got a match (%d), expected none (note: %v)", offset, notation))
 	}
@@ -95,7 +95,7 @@ func gotError(t *testing.T, found bool, offset uint32, err error, expectedError
 	}
 }
 
-func expectList(t *testing.T, in <-chan Result, values []uint32, notation interface{}) {
+func expectList(t *testing.T, in <-chan Result, values []uint64, notation interface{}) {
 	var r Result
 	var ok bool
 
@@ -200,23 +200,23 @@ func TestAllNotFound(t *testing.T) {
 
 func TestAllOfMany(t *testing.T) {
 	c := IndexesOfStr("to be or not to be, that is the becoming question", "be")
-	expectList(t, c, []uint32{3, 16, 32}, "TestAllOfMany")
+	expectList(t, c, []uint64{3, 16, 32}, "TestAllOfMany")
 }
 
 func TestAllOfOverlapping(t *testing.T) {
 	c := IndexesOfStr("many bananas", "ana")
-	expectList(t, c, []uint32{6, 8}, "TestAllOfOverlapping")
+	expectList(t, c, []uint64{6, 8}, "TestAllOfOverlapping")
 }
 
 func TestAllOfOverlapping2(t *testing.T) {
 	c := IndexesOfStr("abcaaadeaaaaf", "aa")
-	expectList(t, c, []uint32{3, 4, 8, 9, 10}, "TestAllOfOverlapping2")
+	expectList(t, c, []uint64{3, 4, 8, 9, 10}, "TestAllOfOverlapping2")
 }
 
 func TestSmallReader(t *testing.T) {
 	r := strings.NewReader("to be or not to be, that is the becoming question")
 	c := IndexesWithinReaderStr(r, "be")
-	expectList(t, c, []uint32{3, 16, 32}, "TestSmallReader")
+	expectList(t, c, []uint64{3, 16, 32}, "TestSmallReader")
 }
 
 func TestHugeReaderAll(t *testing.T) {
@@ -275,3 +275,28 @@ func prepBuffer3(size int) (*bytes.Buffer, string, uint32) {
 	}
 	return buffer, "unto", uint32(0)
 }
+
+func TestBytesWindows(t *testing.T) {
+	defer func(size int) { bytesWindowSize = size }(bytesWindowSize)
+	bytesWindowSize = 8
+
+	// matches of both needles fall on and across the window boundaries
+	data := "ABCDxABCxxABCDABxxABCDABCxAB"
+	set := NewNeedleSet(NewNeedleStr("ABCD"), NewNeedleStr("AB"))
+	var want []Result
+	for i := 0; i < len(data); i++ {
+		for p, needle := range []string{"ABCD", "AB"} {
+			if strings.HasPrefix(data[i:], needle) {
+				want = append(want, Result{uint64(i), nil, p})
+			}
+		}
+	}
+
+	var got []Result
+	for r := range IndexesSet(NewHaystackStr(data), set) {
+		got = append(got, r)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Error(fmt.Sprintf("got %v; expected %v", got, want))
+	}
+}
@@ -0,0 +1,56 @@
+/*
+This file implements chained searches: finding one needle and then another
+that follows it, as when locating a structured header followed by a
+payload marker, in a single streaming pass.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+// A window size that places no limit on the distance between matches.
+const Unbounded = ^uint64(0)
+
+// Searches haystack for a match of first followed by a match of second that
+// begins after the first ends, with no more than window bytes between them
+// (pass Unbounded for no limit). Both needles are searched for in one pass,
+// which stops as soon as such a pair is found. The pair reported is the
+// one with the earliest match of second; its match of first is the latest
+// one that ends before second begins.
+func IndexThen(haystack Haystack, first, second *Needle, window uint64, opts ...Option) (found bool, firstOffset, secondOffset uint64, e error) {
+	firstLen := uint64(first.length)
+	pending := make([]uint64, 0) // matches of first that had not ended at the last match seen
+	latest := uint64(0)          // the latest match of first known to have ended
+	haveLatest := false
+
+	// moves the matches of first that end by offset into latest, so that
+	// pending holds only those overlapping the latest match, however many
+	// there are before a match of second
+	settle := func(offset uint64) {
+		for len(pending) > 0 && pending[0]+firstLen <= offset {
+			latest, haveLatest = pending[0], true
+			pending = pending[1:]
+		}
+	}
+
+	run(haystack, NewNeedleSet(first, second), orderedConfig(opts), func(r Result) bool {
+		if r.Error != nil {
+			e = r.Error
+			return false
+		}
+		settle(r.Offset)
+		if r.Pattern == 0 {
+			pending = append(pending, r.Offset)
+			return true
+		}
+
+		if haveLatest && r.Offset-(latest+firstLen) <= window {
+			found, firstOffset, secondOffset = true, latest, r.Offset
+			return false
+		}
+		return true
+	})
+
+	return
+}
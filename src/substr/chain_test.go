@@ -0,0 +1,92 @@
+/*
+This file includes tests for the chained searches of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func expectChain(t *testing.T, haystack string, window uint64, expectFound bool, expectFirst, expectSecond uint64, notation interface{}) {
+	found, first, second, err := IndexThen(NewHaystackStr(haystack), NewNeedleStr("HDR"), NewNeedleStr("DATA"), window)
+	if err != nil {
+		t.Error(fmt.Sprintf("got unexpected error %s (note: %v)", err, notation))
+	}
+	if found != expectFound {
+		t.Error(fmt.Sprintf("expected found=%v got %v (note: %v)", expectFound, found, notation))
+	} else if found && (first != expectFirst || second != expectSecond) {
+		t.Error(fmt.Sprintf("expected %d then %d, got %d then %d (note: %v)", expectFirst, expectSecond, first, second, notation))
+	}
+}
+
+func TestChain(t *testing.T) {
+	expectChain(t, "xxHDRxxDATAxx", Unbounded, true, 2, 7, "simple")
+	expectChain(t, "DATA HDR", Unbounded, false, 0, 0, "wrong order")
+	expectChain(t, "xxHDRxxxxxxxxDATA", 4, false, 0, 0, "outside window")
+	expectChain(t, "HDRxxxxxxxxDATA HDRxDATA", 4, true, 16, 20, "second pair within window")
+	expectChain(t, "HDRHDRDATA", 0, true, 3, 6, "adjacent")
+	expectChain(t, "HDRxHDATA", Unbounded, true, 0, 5, "overlap")
+	expectChain(t, strings.Repeat("HDR", 1000)+"xDATA", 1, true, 2997, 3001, "many matches of first before second")
+}
+
+func TestChainOverlappingFirst(t *testing.T) {
+	// a match of first still under way when the next begins is kept
+	found, first, second, err := IndexThen(NewHaystackStr("ababaDATA ababDATA"), NewNeedleStr("aba"), NewNeedleStr("DATA"), 0)
+	if err != nil || !found || first != 2 || second != 5 {
+		t.Error(fmt.Sprintf("unexpected result found=%v %d %d %v", found, first, second, err))
+	}
+	found, first, second, err = IndexThen(NewHaystackStr("abababDATA"), NewNeedleStr("aba"), NewNeedleStr("bDATA"), Unbounded)
+	if err != nil || !found || first != 2 || second != 5 {
+		t.Error(fmt.Sprintf("unexpected result found=%v %d %d %v", found, first, second, err))
+	}
+}
+
+func TestChainStreaming(t *testing.T) {
+	data := prepPlaced(3*buffSize, "HDR", []uint64{buffSize - 1})
+	copy(data[2*buffSize+2:], "DATA")
+	found, first, second, err := IndexThen(NewHaystackReader(bytes.NewReader(data)), NewNeedleStr("HDR"), NewNeedleStr("DATA"), Unbounded)
+	if err != nil || !found || first != buffSize-1 || second != 2*buffSize+2 {
+		t.Error(fmt.Sprintf("unexpected result found=%v %d %d %v", found, first, second, err))
+	}
+}
+
+func TestNeedleSet(t *testing.T) {
+	set := NewNeedleSet(NewNeedleStr("be"), NewNeedleStr("to be"), NewNeedleStr("t"))
+	data := prepPlaced(2*buffSize, "to be", []uint64{0, buffSize - 2})
+	for name, haystack := range map[string]Haystack{
+		"bytes":  NewHaystackBytes(data),
+		"reader": NewHaystackReader(bytes.NewReader(data)),
+	} {
+		got := make([]string, 0)
+		for r := range IndexesSet(haystack, set, WithCrossCheck()) {
+			if r.Error != nil {
+				t.Error(r.Error)
+			}
+			got = append(got, fmt.Sprintf("%d:%d", r.Offset, r.Pattern))
+		}
+		expect := fmt.Sprintf("[0:1 0:2 3:0 %d:1 %d:2 %d:0]", buffSize-2, buffSize-2, buffSize+1)
+		if fmt.Sprint(got) != expect {
+			t.Error(fmt.Sprintf("expected %s got %v (note: %s)", expect, got, name))
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	set := NewNeedleSet(NewNeedleStr("be"), NewNeedleStr("question"), NewNeedleStr("unto"))
+	coverage, err := Coverage(NewHaystackStr("to be or not to be, that is the question"), set, WithParallel(2), WithChunkSize(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprintf("%v %v %v", coverage[0], coverage[1], coverage[2])
+	if got != "{2 3} {1 32} {0 0}" || !coverage[1].Matched() || coverage[2].Matched() {
+		t.Error(fmt.Sprintf("unexpected coverage %s", got))
+	}
+}
@@ -0,0 +1,76 @@
+/*
+This file implements the cross-check debugging mode, in which the results
+of the optimized search are compared against a naive scan of the same data.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// The error reported when the optimized search and the naive scan disagree
+// (see WithCrossCheck). Base is the offset of the block of data in which
+// they disagreed; Optimized and Naive are the offsets each found there.
+type CrossCheckError struct {
+	Base      uint64
+	Optimized []uint64
+	Naive     []uint64
+}
+
+func (e *CrossCheckError) Error() string {
+	return fmt.Sprintf("boyer_moore: cross-check failed in block at offset %d; search found %v, naive scan found %v", e.Base, e.Optimized, e.Naive)
+}
+
+// Cross-checks each needle of set (see crossCheck); found is indexed like
+// the needles.
+func crossCheckSet(window []byte, set *NeedleSet, limit uint32, base uint64, found [][]uint32, complete bool) error {
+	for i, needle := range set.needles {
+		if err := crossCheck(window, needle, limit, base, found[i], complete); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compares the indexes found within window by the optimized search with
+// those found by bytes.Index, considering only matches that begin before
+// limit. If complete is false the search stopped early, so only as many
+// naive matches as were found are compared.
+func crossCheck(window []byte, needle *Needle, limit uint32, base uint64, found []uint32, complete bool) error {
+	naive := make([]uint32, 0, len(found))
+	for start := 0; uint32(start) < limit; {
+		i := bytes.Index(window[start:], needle.bytes)
+		if i < 0 || uint32(start+i) >= limit {
+			break
+		}
+		naive = append(naive, uint32(start+i))
+		start += i + 1
+	}
+	if !complete && len(naive) > len(found) {
+		naive = naive[:len(found)]
+	}
+
+	same := len(naive) == len(found)
+	for i := 0; same && i < len(found); i++ {
+		same = naive[i] == found[i]
+	}
+	if same {
+		return nil
+	}
+
+	return &CrossCheckError{base, absolute(found, base), absolute(naive, base)}
+}
+
+// Returns indexes converted to offsets by adding base.
+func absolute(indexes []uint32, base uint64) []uint64 {
+	offsets := make([]uint64, len(indexes))
+	for i, index := range indexes {
+		offsets[i] = base + uint64(index)
+	}
+	return offsets
+}
@@ -0,0 +1,44 @@
+/*
+This file includes tests for the cross-check debugging mode of the substr
+package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestCrossCheckAgrees(t *testing.T) {
+	functions := []func(int) (*bytes.Buffer, string, uint32){prepBuffer1, prepBuffer2, prepBuffer3}
+	for funcIndex, function := range functions {
+		buffer, needle, expect := function(9 * 1024)
+		expectCount(t, Indexes(NewHaystackBytes(buffer.Bytes()), NewNeedleStr(needle), WithCrossCheck()), expect, funcIndex)
+		r := bytes.NewReader(buffer.Bytes())
+		expectCount(t, Indexes(NewHaystackReader(r), NewNeedleStr(needle), WithCrossCheck()), expect, funcIndex)
+		expectCount(t, Indexes(NewHaystackBytes(buffer.Bytes()), NewNeedleStr(needle), WithCrossCheck(), WithParallel(3), WithChunkSize(500)), expect, funcIndex)
+	}
+}
+
+func TestCrossCheckFirst(t *testing.T) {
+	found, offset, err := Index(NewHaystackStr("to be or not to be"), NewNeedleStr("be"), WithCrossCheck())
+	got1(t, found, offset, err, 3, "TestCrossCheckFirst")
+}
+
+func TestCrossCheckDiverges(t *testing.T) {
+	// corrupt the needle's tables so that the optimized search skips matches
+	needle := NewNeedleStr("abc")
+	for i := range needle.charTable {
+		needle.charTable[i] = 100
+	}
+	results, err := convert(Indexes(NewHaystackStr("xxabcxxabc"), needle, WithCrossCheck()))
+	if _, ok := err.(*CrossCheckError); !ok {
+		t.Error(fmt.Sprintf("expected a CrossCheckError, got %v (results %v)", err, results))
+	}
+}
@@ -0,0 +1,72 @@
+/*
+This file exposes the tables of a pre-processed Needle, so that users can
+see why a particular pattern shifts poorly and teachers can demonstrate the
+Boyer-Moore algorithm.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Returns a copy of the needle's bytes.
+func (n *Needle) Bytes() []byte {
+	return append([]byte(nil), n.bytes...)
+}
+
+// Returns the length of the needle in bytes.
+func (n *Needle) Len() int {
+	return int(n.length)
+}
+
+// Returns the bad-character table: for each byte value, how far the search
+// may shift when that byte in the haystack causes a mismatch.
+func (n *Needle) BadCharTable() [byteCount]uint32 {
+	return n.charTable
+}
+
+// Returns a copy of the good-suffix table: entry i is how far the search
+// may shift when a mismatch occurs after i bytes at the end of the needle
+// have matched.
+func (n *Needle) GoodSuffixTable() []uint32 {
+	return append([]uint32(nil), n.offsetTable...)
+}
+
+// Writes a human-readable description of the needle and its tables to w.
+// Bad-character entries equal to the needle's length (the shift for any
+// byte that does not occur in the needle) are summarized on one line.
+func (n *Needle) Dump(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "needle: %q (%d bytes)\n", n.bytes, n.length)
+
+	fmt.Fprintf(out, "bad-character table:\n")
+	for b := 0; b < byteCount; b++ {
+		if shift := n.charTable[b]; shift != n.length {
+			fmt.Fprintf(out, "    %-6s shift %d\n", byteName(byte(b)), shift)
+		}
+	}
+	fmt.Fprintf(out, "    others shift %d\n", n.length)
+
+	fmt.Fprintf(out, "good-suffix table:\n")
+	for i, shift := range n.offsetTable {
+		fmt.Fprintf(out, "    %3d matched  shift %d\n", i, shift)
+	}
+
+	return out.Flush()
+}
+
+// Returns a printable name for b: the character itself, quoted, if it is
+// printable ASCII, otherwise its value in hex.
+func byteName(b byte) string {
+	if b >= 0x20 && b < 0x7f {
+		return fmt.Sprintf("%q", b)
+	}
+	return fmt.Sprintf("0x%02x", b)
+}
@@ -0,0 +1,55 @@
+/*
+This file includes tests for the Needle introspection functions of the
+substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestNeedleTables(t *testing.T) {
+	n := NewNeedleStr("ANPANMAN")
+	table := n.BadCharTable()
+	for b, expect := range map[byte]uint32{'A': 1, 'M': 2, 'N': 3, 'P': 5, 'x': 8} {
+		if table[b] != expect {
+			t.Error(fmt.Sprintf("expected bad-character shift %d for %q got %d", expect, b, table[b]))
+		}
+	}
+	if s := n.GoodSuffixTable(); len(s) != n.Len() {
+		t.Error(fmt.Sprintf("expected %d good-suffix entries got %d", n.Len(), len(s)))
+	}
+
+	b := n.Bytes()
+	b[0] = 'x'
+	if string(n.Bytes()) != "ANPANMAN" {
+		t.Error("modifying the result of Bytes changed the needle")
+	}
+}
+
+func TestNeedleDump(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewNeedleBytes([]byte("ab\x00")).Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := "" +
+		"needle: \"ab\\x00\" (3 bytes)\n" +
+		"bad-character table:\n" +
+		"    'a'    shift 2\n" +
+		"    'b'    shift 1\n" +
+		"    others shift 3\n" +
+		"good-suffix table:\n" +
+		"      0 matched  shift 1\n" +
+		"      1 matched  shift 4\n" +
+		"      2 matched  shift 5\n"
+	if buf.String() != expect {
+		t.Error(fmt.Sprintf("expected\n%s\ngot\n%s", expect, buf.String()))
+	}
+}
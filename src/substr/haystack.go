@@ -0,0 +1,138 @@
+/*
+This file defines the Haystack abstraction, the data to be searched. The
+search entry points (Index and Indexes) accept any Haystack, so that the
+same functions serve in-memory data, streams, and files; the older
+Str/Bytes/Reader variants are shorthands for them.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// The data to be searched.
+type Haystack interface {
+	// Returns a reader positioned at the start of the data.
+	Reader() (io.Reader, error)
+
+	// Returns the size of the data in bytes, or -1 if it is not known in
+	// advance.
+	Size() int64
+}
+
+// A Haystack whose data can also be read at arbitrary offsets, provided
+// its Size is not negative.
+type randomAccessHaystack interface {
+	Haystack
+	ReaderAt() io.ReaderAt
+}
+
+//// TYPE bytesHaystack ////
+
+type bytesHaystack []byte
+
+// Returns a Haystack that searches the bytes given.
+func NewHaystackBytes(haystack []byte) Haystack {
+	return bytesHaystack(haystack)
+}
+
+// Returns a Haystack that searches the string given.
+func NewHaystackStr(haystack string) Haystack {
+	return bytesHaystack(haystack)
+}
+
+func (h bytesHaystack) Reader() (io.Reader, error) {
+	return bytes.NewReader(h), nil
+}
+
+func (h bytesHaystack) ReaderAt() io.ReaderAt {
+	return bytes.NewReader(h)
+}
+
+func (h bytesHaystack) Size() int64 {
+	return int64(len(h))
+}
+
+//// TYPE readerHaystack ////
+
+type readerHaystack struct {
+	r io.Reader
+}
+
+// Returns a Haystack that searches the data read from r. Such a Haystack
+// can only be searched once, since searching consumes r.
+func NewHaystackReader(r io.Reader) Haystack {
+	return &readerHaystack{r}
+}
+
+func (h *readerHaystack) Reader() (io.Reader, error) {
+	return h.r, nil
+}
+
+func (h *readerHaystack) Size() int64 {
+	return -1
+}
+
+//// TYPE readerAtHaystack ////
+
+type readerAtHaystack struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// Returns a Haystack that searches the first size bytes of r.
+func NewHaystackReaderAt(r io.ReaderAt, size int64) Haystack {
+	return &readerAtHaystack{r, size}
+}
+
+func (h *readerAtHaystack) Reader() (io.Reader, error) {
+	return io.NewSectionReader(h.r, 0, h.size), nil
+}
+
+func (h *readerAtHaystack) ReaderAt() io.ReaderAt {
+	return h.r
+}
+
+func (h *readerAtHaystack) Size() int64 {
+	return h.size
+}
+
+//// TYPE fileHaystack ////
+
+type fileHaystack struct {
+	f *os.File
+}
+
+// Returns a Haystack that searches the contents of f from its beginning,
+// regardless of f's current position. If f's size cannot be determined
+// (e.g., it is a pipe) it is searched as a stream from its current
+// position.
+func NewHaystackFile(f *os.File) Haystack {
+	return &fileHaystack{f}
+}
+
+func (h *fileHaystack) Reader() (io.Reader, error) {
+	size := h.Size()
+	if size < 0 {
+		return h.f, nil
+	}
+	return io.NewSectionReader(h.f, 0, size), nil
+}
+
+func (h *fileHaystack) ReaderAt() io.ReaderAt {
+	return h.f
+}
+
+func (h *fileHaystack) Size() int64 {
+	info, err := h.f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return -1
+	}
+	return info.Size()
+}
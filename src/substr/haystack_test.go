@@ -0,0 +1,129 @@
+/*
+This file includes tests for the Haystack implementations of the substr
+package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// a haystack of size bytes with needle placed at each of offsets
+func prepPlaced(size int, needle string, offsets []uint64) []byte {
+	data := bytes.Repeat([]byte("."), size)
+	for _, offset := range offsets {
+		copy(data[offset:], needle)
+	}
+	return data
+}
+
+func TestHaystackKinds(t *testing.T) {
+	offsets := []uint64{0, buffSize - 3, 2 * buffSize, 3*buffSize - 6, 3*buffSize + 7}
+	data := prepPlaced(3*buffSize+20, "needle", offsets)
+
+	f, err := os.CreateTemp(t.TempDir(), "haystack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	haystacks := map[string]Haystack{
+		"bytes":    NewHaystackBytes(data),
+		"string":   NewHaystackStr(string(data)),
+		"reader":   NewHaystackReader(bytes.NewReader(data)),
+		"readerAt": NewHaystackReaderAt(bytes.NewReader(data), int64(len(data))),
+		"file":     NewHaystackFile(f),
+	}
+	needle := NewNeedleStr("needle")
+	for name, haystack := range haystacks {
+		expectList(t, Indexes(haystack, needle), offsets, name)
+	}
+}
+
+func TestHaystackSize(t *testing.T) {
+	if s := NewHaystackStr("abc").Size(); s != 3 {
+		t.Error(fmt.Sprintf("expected size 3, got %d", s))
+	}
+	if s := NewHaystackReader(strings.NewReader("abc")).Size(); s != -1 {
+		t.Error(fmt.Sprintf("expected size -1, got %d", s))
+	}
+}
+
+func TestIndexFirst(t *testing.T) {
+	data := prepPlaced(2*buffSize, "needle", []uint64{buffSize - 2, buffSize + 100})
+	found, offset, err := Index(NewHaystackReader(bytes.NewReader(data)), NewNeedleStr("needle"))
+	got1(t, found, offset, err, buffSize-2, "TestIndexFirst")
+}
+
+func TestLongNeedleReader(t *testing.T) {
+	long := strings.Repeat("0123456789", buffSize/5)
+	data := prepPlaced(5*buffSize, long, []uint64{3, 3*buffSize + 1})
+	c := Indexes(NewHaystackReader(bytes.NewReader(data)), NewNeedleStr(long))
+	expectList(t, c, []uint64{3, 3*buffSize + 1}, "TestLongNeedleReader")
+}
+
+func TestBaseOffset(t *testing.T) {
+	data := prepPlaced(3*buffSize, "needle", []uint64{10, 2 * buffSize})
+	section := io.NewSectionReader(bytes.NewReader(data), 5, int64(len(data))-5)
+	base := uint64(1<<40 + 5)
+	c := Indexes(NewHaystackReaderAt(section, section.Size()), NewNeedleStr("needle"), WithBaseOffset(base))
+	expectList(t, c, []uint64{base + 5, base + 2*buffSize - 5}, "TestBaseOffset")
+
+	c = Indexes(NewHaystackReaderAt(section, section.Size()), NewNeedleStr("needle"), WithBaseOffset(base), WithParallel(2), WithChunkSize(1000))
+	expectList(t, c, []uint64{base + 5, base + 2*buffSize - 5}, "TestBaseOffset parallel")
+}
+
+func TestLimit(t *testing.T) {
+	data := prepPlaced(3*buffSize, "needle", []uint64{10, 100, buffSize, 2 * buffSize})
+	c := Indexes(NewHaystackBytes(data), NewNeedleStr("needle"), WithLimit(2))
+	expectList(t, c, []uint64{10, 100}, "TestLimit")
+
+	c = Indexes(NewHaystackReader(bytes.NewReader(data)), NewNeedleStr("needle"), WithLimit(3))
+	expectList(t, c, []uint64{10, 100, buffSize}, "TestLimit reader")
+
+	c = Indexes(NewHaystackBytes(data), NewNeedleStr("needle"), WithLimit(3), WithParallel(4), WithChunkSize(1000))
+	expectList(t, c, []uint64{10, 100, buffSize}, "TestLimit parallel")
+
+	c = Indexes(NewHaystackBytes(data), NewNeedleStr("needle"), WithLimit(0))
+	expectList(t, c, []uint64{10, 100, buffSize, 2 * buffSize}, "TestLimit none")
+}
+
+func TestCancel(t *testing.T) {
+	data := bytes.Repeat([]byte("needle "), 10*buffSize)
+	done := make(chan struct{})
+	close(done)
+	expect0(t, Indexes(NewHaystackBytes(data), NewNeedleStr("needle"), WithCancel(done)), "TestCancel before")
+	expect0(t, Indexes(NewHaystackReader(bytes.NewReader(data)), NewNeedleStr("needle"), WithCancel(done)), "TestCancel reader before")
+
+	// cancelled once the first match is received, the search ends well
+	// short of the 40960 matches there are
+	for _, h := range []Haystack{NewHaystackBytes(data), NewHaystackReader(bytes.NewReader(data))} {
+		done = make(chan struct{})
+		count := 0
+		for r := range Indexes(h, NewNeedleStr("needle"), WithCancel(done)) {
+			if r.Error != nil {
+				t.Error(fmt.Sprintf("got unexpected error %s", r.Error))
+			}
+			if count == 0 {
+				close(done)
+			}
+			count++
+		}
+		if count > 2*outChanSize {
+			t.Error(fmt.Sprintf("expected the search to end soon after being cancelled; got %d matches", count))
+		}
+	}
+}
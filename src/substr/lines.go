@@ -0,0 +1,105 @@
+/*
+This file implements a line-oriented search on top of the Boyer-Moore
+matcher, so that grep-like consumers can receive whole matching lines
+without buffering the input themselves.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"bufio"
+	"io"
+)
+
+// A line that contained at least one match of the needle. LineNumber is
+// 1-based; Offset is the offset of the first match on the line within the
+// data searched; LineStart is the offset of the line's first byte. Line
+// holds the line's contents without its terminating newline (or carriage
+// return/newline pair). When searching for a NeedleSet, Pattern is the
+// index of the needle of the first match. If Error is not nil, the other
+// fields are unset.
+type LineMatch struct {
+	LineNumber uint64
+	Offset     uint64
+	LineStart  uint64
+	Line       []byte
+	Pattern    int
+	Error      error
+}
+
+// Searches for needle within haystack one line at a time, sending a
+// LineMatch on the returned channel for every line containing at least one
+// match. Lines may be of any length; a line that straddles the reader's
+// internal buffer is accumulated before it is searched.
+func LineMatches(haystack io.Reader, needle *Needle) <-chan LineMatch {
+	return LineMatchesSet(haystack, NewNeedleSet(needle))
+}
+
+// Like LineMatches, but sends a LineMatch for every line containing a
+// match of any of the needles of set. If matches of several needles start
+// at the line's first match offset, Pattern is the first of them within
+// the set.
+func LineMatchesSet(haystack io.Reader, set *NeedleSet) <-chan LineMatch {
+	out := make(chan LineMatch, outChanSize)
+
+	go func() {
+		defer close(out)
+
+		if set.empty() {
+			out <- LineMatch{Error: ErrEmptyNeedle}
+			return
+		}
+
+		in := bufio.NewReaderSize(haystack, buffSize)
+		lineNumber := uint64(0)
+		lineStart := uint64(0)
+
+		for {
+			line, err := in.ReadBytes('\n')
+			if len(line) > 0 {
+				lineNumber++
+				content := trimEOL(line)
+				index, pattern := uint32(errorOffset), 0
+				for i, needle := range set.needles {
+					found := indexOfHelper(content, needle, uint32(len(content)), 0)
+					if found < index {
+						index, pattern = found, i
+					}
+				}
+				if index != errorOffset {
+					out <- LineMatch{
+						LineNumber: lineNumber,
+						Offset:     lineStart + uint64(index),
+						LineStart:  lineStart,
+						Line:       content,
+						Pattern:    pattern}
+				}
+				lineStart += uint64(len(line))
+			}
+
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				out <- LineMatch{Error: err}
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Returns line without its trailing "\n" or "\r\n", if any.
+func trimEOL(line []byte) []byte {
+	l := len(line)
+	if l > 0 && line[l-1] == '\n' {
+		l--
+		if l > 0 && line[l-1] == '\r' {
+			l--
+		}
+	}
+	return line[:l]
+}
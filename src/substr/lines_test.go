@@ -0,0 +1,107 @@
+/*
+This file includes tests for the line-oriented search of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func expectLines(t *testing.T, in <-chan LineMatch, numbers []uint64, offsets []uint64, notation interface{}) {
+	i := 0
+	for m := range in {
+		if m.Error != nil {
+			t.Error(fmt.Sprintf("expected no error got %v (note: %v)", m.Error, notation))
+			continue
+		}
+		if i >= len(numbers) {
+			t.Error(fmt.Sprintf("expected %d lines, got more; line %d (note: %v)", len(numbers), m.LineNumber, notation))
+			continue
+		}
+		if m.LineNumber != numbers[i] || m.Offset != offsets[i] {
+			t.Error(fmt.Sprintf("expected line %d at %d got line %d at %d (note: %v)", numbers[i], offsets[i], m.LineNumber, m.Offset, notation))
+		}
+		i++
+	}
+	if i < len(numbers) {
+		t.Error(fmt.Sprintf("got %d lines, expected %d (note: %v)", i, len(numbers), notation))
+	}
+}
+
+func TestLinesSimple(t *testing.T) {
+	r := strings.NewReader("to be\nor not\r\nto be, to be\nthat is")
+	c := LineMatches(r, NewNeedleStr("be"))
+	expectLines(t, c, []uint64{1, 3}, []uint64{3, 17}, "TestLinesSimple")
+}
+
+func TestLinesSet(t *testing.T) {
+	text := "to be\nis it\nor not\nnone"
+	set := NewNeedleSet(NewNeedleStr("is"), NewNeedleStr("t"), NewNeedleStr("xyz"))
+	expectLines(t, LineMatchesSet(strings.NewReader(text), set), []uint64{1, 2, 3}, []uint64{0, 6, 17}, "TestLinesSet")
+
+	patterns := []int{1, 0, 1}
+	i := 0
+	for m := range LineMatchesSet(strings.NewReader(text), set) {
+		if i < len(patterns) && m.Pattern != patterns[i] {
+			t.Error(fmt.Sprintf("expected pattern %d on line %d, got %d", patterns[i], m.LineNumber, m.Pattern))
+		}
+		i++
+	}
+}
+
+func TestLinesContent(t *testing.T) {
+	r := strings.NewReader("alpha\r\nbeta\ngamma")
+	for m := range LineMatches(r, NewNeedleStr("a")) {
+		if m.Error != nil {
+			t.Error(m.Error)
+		} else if bytes.ContainsAny(m.Line, "\r\n") {
+			t.Error(fmt.Sprintf("line %d contains end-of-line characters: %q", m.LineNumber, m.Line))
+		}
+	}
+}
+
+func TestLinesEmptyNeedle(t *testing.T) {
+	c := LineMatches(strings.NewReader("abc"), NewNeedleStr(""))
+	m, ok := <-c
+	if !ok || m.Error != ErrEmptyNeedle {
+		t.Error(fmt.Sprintf("expected error %v, got %v", ErrEmptyNeedle, m.Error))
+	}
+}
+
+func TestLinesStraddleBuffer(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("first\n")
+	buffer.WriteString(strings.Repeat("x", 3*buffSize))
+	buffer.WriteString("needle\nlast needle\n")
+	c := LineMatches(bytes.NewReader(buffer.Bytes()), NewNeedleStr("needle"))
+	expectLines(t, c, []uint64{2, 3}, []uint64{6 + 3*buffSize, 18 + 3*buffSize}, "TestLinesStraddleBuffer")
+}
+
+func TestLinesFile(t *testing.T) {
+	f, err := os.Open("../../test_data/gettysburg.txt")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	count := 0
+	for m := range LineMatches(f, NewNeedleStr("nation")) {
+		if m.Error != nil {
+			t.Error(m.Error)
+		} else if !bytes.Contains(m.Line, []byte("nation")) {
+			t.Error(fmt.Sprintf("line %d does not contain the needle: %q", m.LineNumber, m.Line))
+		}
+		count++
+	}
+	if count == 0 {
+		t.Error("expected at least one matching line in gettysburg.txt")
+	}
+}
@@ -0,0 +1,108 @@
+/*
+This file implements searching for several needles at once, in a single
+pass over the haystack.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+// A set of pre-processed needles that are searched for together. Each
+// Result of a search for a NeedleSet identifies the needle matched by its
+// index within the set (Result.Pattern).
+type NeedleSet struct {
+	needles []*Needle
+	maxLen  uint32
+}
+
+// Returns a NeedleSet containing needles, in the order given.
+func NewNeedleSet(needles ...*Needle) *NeedleSet {
+	set := &NeedleSet{needles: needles}
+	for _, needle := range needles {
+		if needle.length > set.maxLen {
+			set.maxLen = needle.length
+		}
+	}
+	return set
+}
+
+// Returns the number of needles in the set.
+func (s *NeedleSet) Len() int {
+	return len(s.needles)
+}
+
+// Returns the i'th needle of the set.
+func (s *NeedleSet) Needle(i int) *Needle {
+	return s.needles[i]
+}
+
+// Returns true if the set has no needles or any of its needles is empty.
+func (s *NeedleSet) empty() bool {
+	if len(s.needles) == 0 {
+		return true
+	}
+	for _, needle := range s.needles {
+		if needle.length == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Searches for all the needles of set within haystack in one pass. Returns
+// any=true if any match is found; first is the first match (the earliest,
+// and of those at the same offset the one whose needle comes first in the
+// set); and e is any error that occurred.
+func IndexSet(haystack Haystack, set *NeedleSet, opts ...Option) (any bool, first Result, e error) {
+	for r := range search(haystack, set, true, opts) {
+		if r.Error != nil {
+			e = r.Error
+		} else {
+			any, first = true, r
+		}
+	}
+	return
+}
+
+// Searches for all the needles of set within haystack in one pass. The
+// results are sent in ascending offset order (unless WithUnordered is
+// given) on the channel returned, which is closed when the search is
+// complete. Matches of different needles at the same offset are sent in
+// the order of the needles within the set.
+func IndexesSet(haystack Haystack, set *NeedleSet, opts ...Option) <-chan Result {
+	return search(haystack, set, false, opts)
+}
+
+// How often one needle of a NeedleSet occurred in a haystack (see
+// Coverage). First is the offset of its first match, if Count is not 0.
+type PatternCoverage struct {
+	Count uint64
+	First uint64
+}
+
+// Returns true if the needle occurred at all.
+func (c PatternCoverage) Matched() bool {
+	return c.Count != 0
+}
+
+// Searches for all the needles of set within haystack in one pass and
+// reports, for each needle (indexed as in the set), whether it matched and
+// how many times. This answers "which of these indicators are present"
+// without the caller having to tally the results.
+func Coverage(haystack Haystack, set *NeedleSet, opts ...Option) (coverage []PatternCoverage, e error) {
+	coverage = make([]PatternCoverage, len(set.needles))
+	run(haystack, set, newConfig(opts), func(r Result) bool {
+		if r.Error != nil {
+			e = r.Error
+			return false
+		}
+		c := &coverage[r.Pattern]
+		if c.Count == 0 || r.Offset < c.First {
+			c.First = r.Offset
+		}
+		c.Count++
+		return true
+	})
+	return
+}
@@ -0,0 +1,237 @@
+/*
+This file defines the options that may be passed to the search entry points
+(Index and Indexes) to modify how a search is carried out.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultChunkSize = 1024 * 1024 // 1MB
+	maxChunkSize     = 1024 * 1024 * 1024
+)
+
+// Modifies how a search is carried out. Options are created by the With...
+// functions.
+type Option func(*config)
+
+// The settings for a single search, as built up from its Options.
+type config struct {
+	bytes       uint64 // first for alignment; updated atomically, as parallel workers share it
+	baseOffset  uint64
+	workers     int
+	chunkSize   int64
+	unordered   bool
+	crossCheck  bool
+	transforms  []Transform
+	anchorStart bool
+	anchorEnd   bool
+	stride      int64
+	sampleSize  int64
+	limit       uint64
+	cancel      <-chan struct{}
+	stats       *Stats
+	start       time.Time
+}
+
+// Returns the settings resulting from applying opts to the defaults.
+func newConfig(opts []Option) *config {
+	cfg := &config{workers: 1, chunkSize: defaultChunkSize}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Like newConfig, but ignores WithUnordered, for searches whose logic
+// depends on receiving matches in order.
+func orderedConfig(opts []Option) *config {
+	cfg := newConfig(opts)
+	cfg.unordered = false
+	return cfg
+}
+
+// Searches haystacks of known size that support random access (those
+// created by NewHaystackBytes, NewHaystackStr, NewHaystackReaderAt, and
+// NewHaystackFile on a regular file) by dividing them into chunks that are
+// searched concurrently by workers goroutines. Other haystacks are searched
+// sequentially. Results are still delivered in ascending offset order
+// unless WithUnordered is also given.
+func WithParallel(workers int) Option {
+	return func(c *config) {
+		if workers > 0 {
+			c.workers = workers
+		}
+	}
+}
+
+// Sets the size of the chunks used by a parallel search (see WithParallel).
+// Sizes over 1GB are reduced to 1GB.
+func WithChunkSize(size int64) Option {
+	return func(c *config) {
+		if size > maxChunkSize {
+			c.chunkSize = maxChunkSize
+		} else if size > 0 {
+			c.chunkSize = size
+		}
+	}
+}
+
+// Allows a parallel search to deliver each chunk's results as soon as they
+// are ready rather than in ascending offset order. Results within a chunk
+// remain in order.
+func WithUnordered() Option {
+	return func(c *config) {
+		c.unordered = true
+	}
+}
+
+// Checks the optimized search against a naive scan based on bytes.Index as
+// it goes. If the two ever disagree, a *CrossCheckError is sent as the
+// final Result. This is slow and is intended for validating the package on
+// unusual data before trusting it.
+func WithCrossCheck() Option {
+	return func(c *config) {
+		c.crossCheck = true
+	}
+}
+
+// Applies t to the haystack's data as it is read, before it is searched;
+// the needle is not transformed. If given more than once, the transforms
+// are applied in the order given. The caller's data is never modified; an
+// in-memory haystack is searched through a buffer instead.
+func WithTransform(t Transform) Option {
+	return func(c *config) {
+		c.transforms = append(c.transforms, t)
+	}
+}
+
+// Applies the configured transforms, if any, to data, which begins at
+// offset within the haystack.
+func (c *config) transform(data []byte, offset uint64) {
+	for _, t := range c.transforms {
+		t(data, offset)
+	}
+}
+
+// Records measurements of the search in s (see Stats).
+func WithStats(s *Stats) Option {
+	return func(c *config) {
+		c.stats = s
+	}
+}
+
+// Notes the start of the search, if statistics were requested.
+func (c *config) startStats() {
+	if c.stats != nil {
+		*c.stats = Stats{}
+		c.start = time.Now()
+	}
+}
+
+// Adds count to the number of bytes scanned.
+func (c *config) scanned(count int) {
+	if c.stats != nil {
+		atomic.AddUint64(&c.bytes, uint64(count))
+	}
+}
+
+// Notes that a match has been delivered.
+func (c *config) matched() {
+	if c.stats != nil {
+		if c.stats.Matches == 0 {
+			c.stats.FirstMatch = time.Since(c.start)
+		}
+		c.stats.Matches++
+	}
+}
+
+// Completes the statistics at the end of the search.
+func (c *config) finishStats() {
+	if c.stats != nil {
+		c.stats.Elapsed = time.Since(c.start)
+		c.stats.BytesScanned = atomic.LoadUint64(&c.bytes)
+	}
+}
+
+// Adds n to the offset of every match reported, so that a search of data
+// that begins part way into something larger (e.g., an io.SectionReader of
+// a partition) reports absolute offsets.
+func WithBaseOffset(n uint64) Option {
+	return func(c *config) {
+		c.baseOffset = n
+	}
+}
+
+// Only accepts matches at the very start of the haystack. Only the first
+// bytes of the haystack are read.
+func WithAnchorStart() Option {
+	return func(c *config) {
+		c.anchorStart = true
+	}
+}
+
+// Only accepts matches that end exactly at the end of the haystack. A
+// haystack of known size that allows random access is only read at its
+// end; others must be read through. Combined with WithAnchorStart, only a
+// needle equal to the whole haystack matches.
+func WithAnchorEnd() Option {
+	return func(c *config) {
+		c.anchorEnd = true
+	}
+}
+
+// Enables a fast, probabilistic mode for haystacks of known size that allow
+// random access: only the first sampleSize bytes of every stride bytes are
+// read, and only a stride whose sample contains the rarest byte of a needle
+// is searched in full. Matches in strides whose sample gives no hint are
+// missed, so this suits "is it plausibly here at all?" triage. Other
+// haystacks are searched in full. Strides over 1GB are reduced to 1GB, and
+// the sample size is limited to the stride.
+func WithSampling(stride, sampleSize int64) Option {
+	return func(c *config) {
+		if stride > maxChunkSize {
+			stride = maxChunkSize
+		}
+		if sampleSize > stride {
+			sampleSize = stride
+		}
+		if stride > 0 && sampleSize > 0 {
+			c.stride, c.sampleSize = stride, sampleSize
+		}
+	}
+}
+
+// Ends the search once n matches have been reported; 0 means no limit. With
+// WithUnordered, which n matches are reported is not defined.
+func WithLimit(n uint64) Option {
+	return func(c *config) {
+		c.limit = n
+	}
+}
+
+// Ends the search once done is closed, so that a caller that has seen
+// enough can stop a search it is receiving the results of. It is checked
+// as each match is reported and before more of the haystack is read.
+func WithCancel(done <-chan struct{}) Option {
+	return func(c *config) {
+		c.cancel = done
+	}
+}
+
+// Has the search been cancelled (see WithCancel)?
+func (c *config) cancelled() bool {
+	select {
+	case <-c.cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+/*
+This file implements a parallel search of random-access haystacks. The
+haystack is divided into chunks that are searched concurrently; a small
+reorder buffer then delivers the results in ascending offset order, which
+consumers such as the swap tool rely upon.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"io"
+)
+
+// The results found within one chunk of a parallel search.
+type chunkResults struct {
+	index   int
+	results []Result
+}
+
+// Sends each match within haystack to emit, until emit returns false, searching chunks of the haystack concurrently. No more than two
+// chunks per worker are searched ahead of the chunk being emitted, which
+// bounds the memory held by the reorder buffer.
+func searchParallel(haystack randomAccessHaystack, set *NeedleSet, cfg *config, emit func(Result) bool) {
+	size := haystack.Size()
+	chunkSize := cfg.chunkSize
+	chunks := int((size + chunkSize - 1) / chunkSize)
+	inFlight := 2 * cfg.workers
+
+	tokens := make(chan bool, inFlight) // one per chunk dispatched but not yet emitted
+	todo := make(chan int)
+	done := make(chan chunkResults, inFlight)
+	quit := make(chan bool)
+	defer close(quit)
+
+	go func() {
+		defer close(todo)
+		for i := 0; i < chunks; i++ {
+			select {
+			case tokens <- true:
+			case <-quit:
+				return
+			}
+			select {
+			case todo <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < cfg.workers; w++ {
+		go func() {
+			for i := range todo {
+				done <- searchChunk(haystack, set, i, size, cfg)
+			}
+		}()
+	}
+
+	pending := make(map[int][]Result)
+	next := 0
+	for next < chunks {
+		c := <-done
+		if cfg.unordered {
+			<-tokens
+			next++
+			if !emitAll(c.results, emit) {
+				return
+			}
+			continue
+		}
+
+		pending[c.index] = c.results
+		for {
+			results, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			<-tokens
+			next++
+			if !emitAll(results, emit) {
+				return
+			}
+		}
+	}
+}
+
+// Searches the index'th chunk of haystack, returning the matches that
+// begin within it. The data read extends past the end of the chunk far
+// enough to find a match that straddles the boundary.
+func searchChunk(haystack randomAccessHaystack, set *NeedleSet, index int, size int64, cfg *config) chunkResults {
+	start := int64(index) * cfg.chunkSize
+	end := start + cfg.chunkSize + int64(set.maxLen) - 1
+	if end > size {
+		end = size
+	}
+
+	var data []byte
+	if b, ok := haystack.(bytesHaystack); ok && len(cfg.transforms) == 0 {
+		data = b[start:end]
+	} else {
+		data = make([]byte, end-start)
+		count, err := haystack.ReaderAt().ReadAt(data, start)
+		if err != nil && !(err == io.EOF && count == len(data)) {
+			return chunkResults{index, []Result{{Offset: errorOffset, Error: err}}}
+		}
+		cfg.transform(data, uint64(start))
+	}
+
+	if own := size - start; own < cfg.chunkSize {
+		cfg.scanned(int(own))
+	} else {
+		cfg.scanned(int(cfg.chunkSize))
+	}
+
+	results := make([]Result, 0)
+	scanWindow(data, set, uint32(cfg.chunkSize), uint64(start), cfg, func(r Result) bool {
+		results = append(results, r)
+		return true
+	})
+	return chunkResults{index, results}
+}
+
+// Sends each of results to emit, stopping early if emit returns false.
+// Returns whether all were sent.
+func emitAll(results []Result, emit func(Result) bool) bool {
+	for _, r := range results {
+		if !emit(r) {
+			return false
+		}
+	}
+	return true
+}
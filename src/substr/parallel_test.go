@@ -0,0 +1,52 @@
+/*
+This file includes tests for the parallel search of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestParallelOrdered(t *testing.T) {
+	offsets := []uint64{0, 94, 100, 195, 400, 1001, 4090}
+	data := prepPlaced(4096, "needle", offsets)
+	haystacks := []Haystack{
+		NewHaystackBytes(data),
+		NewHaystackReaderAt(bytes.NewReader(data), int64(len(data))),
+	}
+	for i, haystack := range haystacks {
+		c := Indexes(haystack, NewNeedleStr("needle"), WithParallel(4), WithChunkSize(100))
+		expectList(t, c, offsets, fmt.Sprintf("TestParallelOrdered %d", i))
+	}
+}
+
+func TestParallelUnordered(t *testing.T) {
+	buffer, needle, expect := prepBuffer1(64 * 1024)
+	c := Indexes(NewHaystackBytes(buffer.Bytes()), NewNeedleStr(needle), WithParallel(8), WithChunkSize(1000), WithUnordered())
+	offsets, err := convert(c)
+	if err != nil {
+		t.Error(err)
+	}
+	if uint32(len(offsets)) != expect {
+		t.Error(fmt.Sprintf("expected %d matches got %d", expect, len(offsets)))
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	sequential, _ := convert(Indexes(NewHaystackBytes(buffer.Bytes()), NewNeedleStr(needle)))
+	if fmt.Sprint(offsets) != fmt.Sprint(sequential) {
+		t.Error("parallel and sequential searches found different offsets")
+	}
+}
+
+func TestParallelFirst(t *testing.T) {
+	data := prepPlaced(10000, "needle", []uint64{5000, 9000})
+	found, offset, err := Index(NewHaystackBytes(data), NewNeedleStr("needle"), WithParallel(4), WithChunkSize(128))
+	got1(t, found, offset, err, 5000, "TestParallelFirst")
+}
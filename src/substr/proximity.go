@@ -0,0 +1,67 @@
+/*
+This file implements proximity queries: finding where two needles occur
+within a given distance of each other, in a single pass with memory bounded
+by the number of matches within that distance.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+// A pair of matches found by a proximity search. A and B are the offsets of
+// the matches of the first and second needles, respectively. If Error is
+// not nil, the offsets are unset.
+type Proximity struct {
+	A     uint64
+	B     uint64
+	Error error
+}
+
+// Searches haystack for places where a match of a and a match of b begin
+// no more than distance bytes apart, in either order. Each such pair is
+// sent on the channel returned, in the order of the later match of the
+// pair; the channel is closed when the search is complete.
+func Near(haystack Haystack, a, b *Needle, distance uint64, opts ...Option) <-chan Proximity {
+	out := make(chan Proximity, outChanSize)
+
+	go func() {
+		defer close(out)
+
+		recent := [2][]uint64{} // matches of each needle within distance of the latest match
+		run(haystack, NewNeedleSet(a, b), orderedConfig(opts), func(r Result) bool {
+			if r.Error != nil {
+				out <- Proximity{Error: r.Error}
+				return false
+			}
+
+			other := 1 - r.Pattern
+			recent[0] = pruneBefore(recent[0], r.Offset, distance)
+			recent[1] = pruneBefore(recent[1], r.Offset, distance)
+			for _, offset := range recent[other] {
+				if r.Pattern == 0 {
+					out <- Proximity{A: r.Offset, B: offset}
+				} else {
+					out <- Proximity{A: offset, B: r.Offset}
+				}
+			}
+			recent[r.Pattern] = append(recent[r.Pattern], r.Offset)
+			return true
+		})
+	}()
+
+	return out
+}
+
+// Returns offsets (which are in ascending order) without those more than
+// distance before current.
+func pruneBefore(offsets []uint64, current, distance uint64) []uint64 {
+	i := 0
+	for i < len(offsets) && current-offsets[i] > distance {
+		i++
+	}
+	if i == 0 {
+		return offsets
+	}
+	return append(offsets[:0], offsets[i:]...)
+}
@@ -0,0 +1,36 @@
+/*
+This file includes tests for the proximity queries of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNear(t *testing.T) {
+	haystack := NewHaystackStr("cat.....dog..........dog.cat...................cat")
+	pairs := make([]string, 0)
+	for p := range Near(haystack, NewNeedleStr("cat"), NewNeedleStr("dog"), 8) {
+		if p.Error != nil {
+			t.Error(p.Error)
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf("%d-%d", p.A, p.B))
+	}
+	if fmt.Sprint(pairs) != "[0-8 25-21]" {
+		t.Error(fmt.Sprintf("unexpected pairs %v", pairs))
+	}
+}
+
+func TestNearEmpty(t *testing.T) {
+	p, ok := <-Near(NewHaystackStr("abc"), NewNeedleStr(""), NewNeedleStr("b"), 1)
+	if !ok || p.Error != ErrEmptyNeedle {
+		t.Error(fmt.Sprintf("expected error %v, got %v", ErrEmptyNeedle, p.Error))
+	}
+}
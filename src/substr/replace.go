@@ -0,0 +1,85 @@
+/*
+This file implements streaming search-and-replace on top of the
+Boyer-Moore matcher.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"io"
+)
+
+// Copies src to dst, replacing each non-overlapping match of needle with
+// the bytes returned by fn. fn is passed the offset of the match within src
+// and the matched bytes; the slice is only valid for the duration of the
+// call. Returning match unchanged leaves that occurrence as is; returning
+// nil or an empty slice deletes it.
+func ReplaceFunc(dst io.Writer, src io.Reader, needle *Needle, fn func(offset uint64, match []byte) []byte) error {
+	if needle.length == 0 {
+		return ErrEmptyNeedle
+	}
+
+	needleLen := int(needle.length)
+	size := buffSize
+	if size < 2*needleLen {
+		size = 2 * needleLen
+	}
+	buffer := make([]byte, size)
+	offset := uint64(0) // offset within src of buffer[0]
+	used := 0
+	eof := false
+
+	for !eof {
+		count, err := io.ReadFull(src, buffer[used:])
+		used += count
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			eof = true
+		} else if err != nil {
+			return err
+		}
+
+		start := 0 // buffer[0:start] has already been written
+		for {
+			index := indexOfHelper(buffer[0:used], needle, uint32(used), uint32(start))
+			if index == errorOffset {
+				break
+			}
+			end := int(index) + needleLen
+			if _, err = dst.Write(buffer[start:index]); err != nil {
+				return err
+			}
+			if _, err = dst.Write(fn(offset+uint64(index), buffer[index:end])); err != nil {
+				return err
+			}
+			start = end
+		}
+
+		// retain a tail that might be the beginning of a match
+		keep := 0
+		if !eof {
+			keep = needleLen - 1
+			if keep > used-start {
+				keep = used - start
+			}
+		}
+		if _, err = dst.Write(buffer[start : used-keep]); err != nil {
+			return err
+		}
+		copy(buffer[0:], buffer[used-keep:used])
+		offset += uint64(used - keep)
+		used = keep
+	}
+
+	return nil
+}
+
+// Copies src to dst, replacing each non-overlapping match of needle with
+// replacement.
+func Replace(dst io.Writer, src io.Reader, needle *Needle, replacement []byte) error {
+	return ReplaceFunc(dst, src, needle, func(uint64, []byte) []byte {
+		return replacement
+	})
+}
@@ -0,0 +1,92 @@
+/*
+This file includes tests for the streaming replacement functions of the
+substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReplaceSimple(t *testing.T) {
+	var out bytes.Buffer
+	err := Replace(&out, strings.NewReader("to be or not to be"), NewNeedleStr("be"), []byte("see"))
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != "to see or not to see" {
+		t.Error(fmt.Sprintf("unexpected result %q", out.String()))
+	}
+}
+
+func TestReplaceNonOverlapping(t *testing.T) {
+	var out bytes.Buffer
+	err := Replace(&out, strings.NewReader("aaaaa"), NewNeedleStr("aa"), []byte("b"))
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != "bba" {
+		t.Error(fmt.Sprintf("unexpected result %q", out.String()))
+	}
+}
+
+func TestReplaceFuncNumbering(t *testing.T) {
+	var out bytes.Buffer
+	count := 0
+	offsets := make([]uint64, 0)
+	err := ReplaceFunc(&out, strings.NewReader("x-x-x"), NewNeedleStr("x"), func(offset uint64, match []byte) []byte {
+		count++
+		offsets = append(offsets, offset)
+		if count == 2 {
+			return match
+		}
+		return []byte(fmt.Sprint(count))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != "1-x-3" {
+		t.Error(fmt.Sprintf("unexpected result %q", out.String()))
+	}
+	if fmt.Sprint(offsets) != "[0 2 4]" {
+		t.Error(fmt.Sprintf("unexpected offsets %v", offsets))
+	}
+}
+
+func TestReplaceHuge(t *testing.T) {
+	buffer, needle, expect := prepBuffer1(9 * 1024)
+	input := buffer.String()
+	var out bytes.Buffer
+	count := uint32(0)
+	err := ReplaceFunc(&out, strings.NewReader(input), NewNeedleStr(needle), func(offset uint64, match []byte) []byte {
+		if input[offset:offset+uint64(len(needle))] != needle {
+			t.Error(fmt.Sprintf("reported offset %d does not hold the needle", offset))
+		}
+		count++
+		return []byte("BECOME")
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if count != expect {
+		t.Error(fmt.Sprintf("expected %d replacements got %d", expect, count))
+	}
+	if out.String() != strings.Replace(input, needle, "BECOME", -1) {
+		t.Error("replaced output differs from strings.Replace")
+	}
+}
+
+func TestReplaceEmpty(t *testing.T) {
+	var out bytes.Buffer
+	if err := Replace(&out, strings.NewReader("abc"), NewNeedleStr(""), nil); err != ErrEmptyNeedle {
+		t.Error(fmt.Sprintf("expected error %v, got %v", ErrEmptyNeedle, err))
+	}
+}
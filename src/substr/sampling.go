@@ -0,0 +1,96 @@
+/*
+This file implements the strided, probabilistic pre-scan mode (see
+WithSampling), for triage scans of very large inputs where missing a match
+is acceptable in exchange for reading only a fraction of the data.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"bytes"
+	"io"
+)
+
+// Bytes that are common in typical data, most common first; a needle's
+// rarest byte is the one appearing latest in (or absent from) this list.
+const commonBytes = "\x00\xff etaoinsrhldcumfpgwybvkxjqzETAOINSRHLDCUMFPGWYBVKXJQZ0123456789.,\n\r\t-_/:;\"'()=<>"
+
+// Returns the byte of needle that is likely to be the rarest in typical
+// data.
+func rareByte(needle []byte) byte {
+	best := needle[0]
+	bestRank := -1
+	for _, b := range needle {
+		rank := bytes.IndexByte([]byte(commonBytes), b)
+		if rank < 0 {
+			return b
+		}
+		if rank > bestRank {
+			best, bestRank = b, rank
+		}
+	}
+	return best
+}
+
+// Divides haystack into blocks of cfg.stride bytes and reads the first
+// cfg.sampleSize bytes of each. Only a block whose sample contains the
+// rarest byte of one of the needles is searched in full; matches beginning
+// within it are sent to emit.
+func searchSampled(haystack randomAccessHaystack, set *NeedleSet, cfg *config, emit func(Result) bool) {
+	var fingerprint [byteCount]bool
+	for _, needle := range set.needles {
+		fingerprint[rareByte(needle.bytes)] = true
+	}
+
+	size := haystack.Size()
+	stride := cfg.stride
+	sample := make([]byte, cfg.sampleSize)
+	block := make([]byte, stride+int64(set.maxLen)-1)
+
+	for start := int64(0); start < size; start += stride {
+		data, err := readAt(haystack, sample, start, size)
+		if err != nil {
+			emit(Result{Offset: errorOffset, Error: err})
+			return
+		}
+		cfg.transform(data, uint64(start))
+		cfg.scanned(len(data))
+
+		hit := false
+		for _, b := range data {
+			if fingerprint[b] {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			continue
+		}
+
+		if data, err = readAt(haystack, block, start, size); err != nil {
+			emit(Result{Offset: errorOffset, Error: err})
+			return
+		}
+		cfg.transform(data, uint64(start))
+		cfg.scanned(len(data))
+		if !scanWindow(data, set, uint32(stride), uint64(start), cfg, emit) {
+			return
+		}
+	}
+}
+
+// Reads into buffer from offset start of haystack, stopping at size.
+// Returns the bytes read.
+func readAt(haystack randomAccessHaystack, buffer []byte, start, size int64) ([]byte, error) {
+	if remaining := size - start; int64(len(buffer)) > remaining {
+		buffer = buffer[:remaining]
+	}
+	count, err := haystack.ReaderAt().ReadAt(buffer, start)
+	if err == io.EOF && count == len(buffer) {
+		err = nil
+	}
+	return buffer[:count], err
+}
@@ -0,0 +1,39 @@
+/*
+This file includes tests for the sampling mode of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRareByte(t *testing.T) {
+	for needle, expect := range map[string]byte{"the": 'h', "\x00\x00e": 'e', "tea\x9c": 0x9c, "zz": 'z'} {
+		if b := rareByte([]byte(needle)); b != expect {
+			t.Error(fmt.Sprintf("expected rare byte %q for %q got %q", expect, needle, b))
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	// the needle's rare byte 'Q' is placed at the start of the first,
+	// third, and fourth strides, so only matches in those strides are found
+	data := prepPlaced(4000, "Q", []uint64{0, 2000, 3005})
+	copy(data[500:], "aQz")
+	copy(data[1500:], "aQz")
+	copy(data[2998:], "aQz")
+	copy(data[3500:], "aQz")
+
+	var stats Stats
+	c := Indexes(NewHaystackBytes(data), NewNeedleStr("aQz"), WithSampling(1000, 10), WithStats(&stats))
+	expectList(t, c, []uint64{500, 2998, 3500}, "TestSampling")
+	if stats.BytesScanned >= uint64(len(data)) {
+		t.Error(fmt.Sprintf("expected fewer than %d bytes scanned, got %d", len(data), stats.BytesScanned))
+	}
+}
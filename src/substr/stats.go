@@ -0,0 +1,44 @@
+/*
+This file defines the measurements that can be taken of a search (see
+WithStats), so that integrators can monitor throughput and compare
+settings.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+import (
+	"fmt"
+	"time"
+)
+
+// Measurements of a single search. They are filled in by the search
+// goroutine and are complete once the channel of results has been closed
+// (for Index, once it has returned).
+//
+// BytesScanned is the amount of haystack data searched; a search that stops
+// early, or one that fails, may not have scanned all of the haystack.
+// FirstMatch is the time from the start of the search until the first match
+// was delivered and is zero if there were no matches.
+type Stats struct {
+	BytesScanned uint64
+	Matches      uint64
+	Elapsed      time.Duration
+	FirstMatch   time.Duration
+}
+
+// Returns the effective scan rate in bytes per second.
+func (s *Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.BytesScanned) / s.Elapsed.Seconds()
+}
+
+// Returns a one-line summary of the measurements.
+func (s *Stats) String() string {
+	return fmt.Sprintf("%d bytes in %v (%.1f MB/s), %d matches, first after %v",
+		s.BytesScanned, s.Elapsed, s.Throughput()/(1024*1024), s.Matches, s.FirstMatch)
+}
@@ -0,0 +1,53 @@
+/*
+This file includes tests for the search measurements of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	buffer, needle, expect := prepBuffer1(9 * 1024)
+	size := uint64(buffer.Len())
+	options := map[string][]Option{
+		"bytes":    {},
+		"parallel": {WithParallel(4), WithChunkSize(1000)},
+	}
+	for name, opts := range options {
+		var stats Stats
+		opts = append(opts, WithStats(&stats))
+		expectCount(t, Indexes(NewHaystackBytes(buffer.Bytes()), NewNeedleStr(needle), opts...), expect, name)
+		if stats.BytesScanned != size {
+			t.Error(fmt.Sprintf("expected %d bytes scanned got %d (note: %v)", size, stats.BytesScanned, name))
+		}
+		if stats.Matches != uint64(expect) {
+			t.Error(fmt.Sprintf("expected %d matches got %d (note: %v)", expect, stats.Matches, name))
+		}
+		if stats.FirstMatch > stats.Elapsed {
+			t.Error(fmt.Sprintf("first match after %v is later than the whole search took, %v (note: %v)", stats.FirstMatch, stats.Elapsed, name))
+		}
+	}
+
+	var stats Stats
+	r := bytes.NewReader(buffer.Bytes())
+	expectCount(t, Indexes(NewHaystackReader(r), NewNeedleStr(needle), WithStats(&stats)), expect, "reader")
+	if stats.BytesScanned != size {
+		t.Error(fmt.Sprintf("expected %d bytes scanned got %d (note: reader)", size, stats.BytesScanned))
+	}
+}
+
+func TestStatsNoMatch(t *testing.T) {
+	var stats Stats
+	found, _, _ := Index(NewHaystackStr("abcdef"), NewNeedleStr("xyz"), WithStats(&stats))
+	if found || stats.Matches != 0 || stats.FirstMatch != 0 || stats.BytesScanned != 6 {
+		t.Error(fmt.Sprintf("unexpected statistics %s", stats.String()))
+	}
+}
@@ -0,0 +1,65 @@
+/*
+This file implements transforms that can be applied to haystack data as it
+is read (see WithTransform), so that lightly-encoded data can be searched
+without first making a decoded copy.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package substr
+
+// Modifies data in place. offset is the offset of data[0] within the
+// haystack, for transforms whose effect depends on position.
+type Transform func(data []byte, offset uint64)
+
+// Returns a Transform that exclusive-ors the data with key, repeated from
+// the start of the haystack. An empty key leaves the data unchanged.
+func XORTransform(key []byte) Transform {
+	keyLen := uint64(len(key))
+	return func(data []byte, offset uint64) {
+		if keyLen == 0 {
+			return
+		}
+		k := offset % keyLen
+		for i := range data {
+			data[i] ^= key[k]
+			k++
+			if k == keyLen {
+				k = 0
+			}
+		}
+	}
+}
+
+// A Transform that swaps the high and low nibbles of each byte.
+func NibbleSwapTransform(data []byte, offset uint64) {
+	for i, b := range data {
+		data[i] = b<<4 | b>>4
+	}
+}
+
+// A Transform that replaces each DNA base with its complement (A with T,
+// C with G, and vice versa), preserving case; other bytes are unchanged.
+func DNAComplementTransform(data []byte, offset uint64) {
+	for i, b := range data {
+		switch b {
+		case 'A':
+			data[i] = 'T'
+		case 'T':
+			data[i] = 'A'
+		case 'C':
+			data[i] = 'G'
+		case 'G':
+			data[i] = 'C'
+		case 'a':
+			data[i] = 't'
+		case 't':
+			data[i] = 'a'
+		case 'c':
+			data[i] = 'g'
+		case 'g':
+			data[i] = 'c'
+		}
+	}
+}
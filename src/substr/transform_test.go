@@ -0,0 +1,61 @@
+/*
+This file includes tests for the haystack transforms of the substr package.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package substr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestTransformXOR(t *testing.T) {
+	key := []byte{0x13, 0x57, 0x9b}
+	plain := prepPlaced(3*buffSize+11, "needle", []uint64{5, buffSize - 2, 2*buffSize + 1})
+	encoded := make([]byte, len(plain))
+	copy(encoded, plain)
+	XORTransform(key)(encoded, 0)
+
+	haystacks := map[string]Haystack{
+		"bytes":  NewHaystackBytes(encoded),
+		"reader": NewHaystackReader(bytes.NewReader(encoded)),
+	}
+	for name, haystack := range haystacks {
+		c := Indexes(haystack, NewNeedleStr("needle"), WithTransform(XORTransform(key)))
+		expectList(t, c, []uint64{5, buffSize - 2, 2*buffSize + 1}, name)
+	}
+
+	c := Indexes(NewHaystackBytes(encoded), NewNeedleStr("needle"), WithTransform(XORTransform(key)), WithParallel(4), WithChunkSize(1000))
+	expectList(t, c, []uint64{5, buffSize - 2, 2*buffSize + 1}, "parallel")
+
+	if bytes.Contains(encoded, []byte("needle")) {
+		t.Error("expected the encoded data not to contain the needle")
+	}
+	check := make([]byte, len(encoded))
+	copy(check, encoded)
+	XORTransform(key)(check, 0)
+	if !bytes.Equal(check, plain) {
+		t.Error("the search modified the caller's data")
+	}
+}
+
+func TestTransformChain(t *testing.T) {
+	data := []byte("xxTAGCxx")
+	c := Indexes(NewHaystackBytes(data), NewNeedleStr("atcg"), WithTransform(DNAComplementTransform), WithTransform(func(d []byte, offset uint64) {
+		copy(d, bytes.ToLower(d))
+	}))
+	expect1(t, c, 2, "TestTransformChain")
+}
+
+func TestTransformNibbleSwap(t *testing.T) {
+	data := []byte{0x00, 0x12, 0x34, 0xab}
+	NibbleSwapTransform(data, 0)
+	if fmt.Sprintf("% x", data) != "00 21 43 ba" {
+		t.Error(fmt.Sprintf("unexpected result % x", data))
+	}
+}
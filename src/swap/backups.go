@@ -0,0 +1,137 @@
+/*
+This file implements swap's policy for the backups it keeps of the files
+it rewrites: how many to keep (-backups), for how long (-backup-max-days),
+and where (-backup-dir), since otherwise a file patched again and again
+gathers backups without end.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"fileutil"
+	"flag"
+	"fmt"
+	"myerr"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var maxBackups *int = flag.Int("backups", 0, "keep at most this many of the most recent backups of each file, removing older ones (0 to keep all)")
+var maxBackupDays *int = flag.Int("backup-max-days", 0, "remove backups of each file made more than this many days ago, though never the one just made (0 to keep all)")
+var backupDir *string = flag.String("backup-dir", "", "keep backups under this directory, in a tree that mirrors the paths of the files, rather than beside them")
+
+//// FUNCTIONS ////
+
+// Applies the backup policy after the file at path was rewritten with its
+// original kept as backupName: moves the backup to -backup-dir, if given,
+// and removes the backups -backups and -backup-max-days say not to keep.
+// As the file has been altered by now, problems are only warned of.
+func manageBackup(path, backupName string) {
+	myerr.Info("%s: the original is kept as %s", path, backupName)
+	template := path
+	if *backupDir != "" {
+		var err error
+		if template, err = moveBackup(path, backupName); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not move the backup %s to %s; %s\n", backupName, *backupDir, err)
+			return
+		}
+	}
+	if err := pruneBackups(template); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not remove old backups of %s; %s\n", path, err)
+	}
+}
+
+// Moves backupName, the backup of the file at path, into the tree under
+// -backup-dir. Returns the path that the backups of the file are numbered
+// from there (e.g., DIR/home/me/data for /home/me/data).
+func moveBackup(path, backupName string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	template := filepath.Join(*backupDir, abs[len(filepath.VolumeName(abs)):])
+	if err = os.MkdirAll(filepath.Dir(template), 0700); err != nil {
+		return "", err
+	}
+
+	dest, f, err := fileutil.MakeNumberedFile(template, "backup")
+	if err != nil {
+		return "", err
+	}
+	if os.Rename(backupName, dest) == nil {
+		f.Close()
+		return template, nil
+	}
+
+	// -backup-dir may be on another file system
+	err = copyBackup(f, backupName)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+	return template, os.Remove(backupName)
+}
+
+// Copies the backup backupName into dest, with its permissions.
+func copyBackup(dest *os.File, backupName string) error {
+	src, err := os.Open(backupName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if err = fileutil.CopyFile(dest, src); err != nil {
+		return err
+	}
+	return dest.Chmod(info.Mode().Perm())
+}
+
+// Removes the backups of template (template.backupN) that -backups and
+// -backup-max-days say not to keep.
+func pruneBackups(template string) error {
+	if *maxBackups <= 0 && *maxBackupDays <= 0 {
+		return nil
+	}
+	names, err := fileutil.NumberedFiles(template, "backup")
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	// the last is the one just made
+	keep := names[:len(names)-1]
+	if *maxBackups > 0 && len(names) > *maxBackups {
+		for _, name := range names[:len(names)-*maxBackups] {
+			if err = os.Remove(name); err != nil {
+				return err
+			}
+		}
+		keep = names[len(names)-*maxBackups : len(names)-1]
+	}
+
+	if *maxBackupDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -*maxBackupDays)
+		for _, name := range keep {
+			info, err := os.Stat(name)
+			if err != nil {
+				return err
+			}
+			if backupTime(info).Before(cutoff) {
+				if err = os.Remove(name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
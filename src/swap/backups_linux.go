@@ -0,0 +1,27 @@
+//go:build linux
+// +build linux
+
+/*
+This file implements the finding of when a backup was made on Linux.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Returns when the backup described by info was made: its change time,
+// which renaming the original to the backup's name sets, unlike its
+// modification time, which is the original's.
+func backupTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Ctim.Sec, st.Ctim.Nsec)
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+/*
+This file implements the finding of when a backup was made on systems
+other than Linux.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// Returns when the backup described by info was made, as nearly as is
+// known: its modification time, which is the original's, and so may be
+// earlier.
+func backupTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,153 @@
+/*
+This file implements the altering of block devices (e.g., a disk or a
+partition), which are read and written a whole logical block at a time:
+each replacement is made by reading the blocks it falls within, changing
+them, and writing them back.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+// the logical block size assumed when the device's cannot be found
+const default_block_size = 512
+
+//// TYPE blockDevice ////
+
+// A block device, or a file opened with -direct, read and written only in
+// whole, aligned blocks.
+type blockDevice struct {
+	f         *os.File
+	blockSize int64
+
+	// with -direct, the blocks are read into and written from memory
+	// aligned to the block size
+	aligned bool
+
+	// for a file opened with -direct, the same file opened normally, for
+	// writing within its last block where that is not a whole one
+	partial *os.File
+}
+
+// Returns f, a block device, for reading and writing in whole blocks of
+// its logical block size.
+func newBlockDevice(f *os.File) *blockDevice {
+	size, err := logicalBlockSize(f)
+	if err != nil || size <= 0 {
+		size = default_block_size
+	}
+	return &blockDevice{f: f, blockSize: size}
+}
+
+// Returns f, opened with -direct, for reading and writing in whole blocks
+// from aligned memory, as writing around the cache requires. partial is
+// the same file opened normally, or nil if f is a block device.
+func newDirectFile(f, partial *os.File) *blockDevice {
+	d := &blockDevice{f: f, blockSize: direct_block_size, aligned: true, partial: partial}
+	if partial == nil {
+		if size, err := logicalBlockSize(f); err == nil && size > direct_block_size {
+			d.blockSize = size
+		}
+	}
+	return d
+}
+
+// Returns the blocks that hold the count bytes at offset, and the offset
+// at which they begin.
+func (d *blockDevice) readBlocks(offset int64, count int) ([]byte, int64, error) {
+	start := offset - offset%d.blockSize
+	end := offset + int64(count)
+	if rem := end % d.blockSize; rem != 0 {
+		end += d.blockSize - rem
+	}
+
+	var blocks []byte
+	if d.aligned {
+		blocks = alignedBuffer(int(end-start), int(d.blockSize))
+	} else {
+		blocks = make([]byte, end-start)
+	}
+	n, err := d.f.ReadAt(blocks, start)
+	if err == io.EOF {
+		err = nil
+	}
+	return blocks[:n], start, err
+}
+
+func (d *blockDevice) ReadAt(p []byte, offset int64) (int, error) {
+	blocks, start, err := d.readBlocks(offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	if offset-start >= int64(len(blocks)) {
+		return 0, io.EOF
+	}
+	n := copy(p, blocks[offset-start:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (d *blockDevice) WriteAt(p []byte, offset int64) (int, error) {
+	blocks, start, err := d.readBlocks(offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	if len(blocks)%int(d.blockSize) != 0 || int64(len(blocks)) < offset-start+int64(len(p)) {
+		if d.partial == nil {
+			return 0, io.ErrShortWrite // a device cannot be extended
+		}
+		// the last block of the file is not a whole one
+		return d.partial.WriteAt(p, offset)
+	}
+	copy(blocks[offset-start:], p)
+	if _, err = d.f.WriteAt(blocks, start); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//// FUNCTIONS ////
+
+// Returns a buffer of size bytes whose start is aligned to align bytes (a
+// power of two).
+func alignedBuffer(size, align int) []byte {
+	buf := make([]byte, size+align)
+	skip := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & uintptr(align-1)); rem != 0 {
+		skip = align - rem
+	}
+	return buf[skip : skip+size : skip+size]
+}
+
+// Is the file at path a block device?
+func isBlockDevice(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0
+}
+
+// Returns the size of the file at path, which for a block device (whose
+// size os.Stat does not give) is found by seeking to its end.
+func fileSize(path string) (int64, error) {
+	if !isBlockDevice(path) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.Seek(0, io.SeekEnd)
+}
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+/*
+This file implements the finding of a block device's logical block size
+on Linux.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const ioctl_blksszget = 0x1268 // BLKSSZGET, from linux/fs.h
+
+// Returns the logical block size of f, a block device.
+func logicalBlockSize(f *os.File) (int64, error) {
+	var size int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctl_blksszget, uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+/*
+This file stands in for the finding of a block device's logical block
+size on systems where it is not implemented.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Returns an error, so that the default block size is assumed.
+func logicalBlockSize(f *os.File) (int64, error) {
+	return 0, errors.New("the logical block size is not known on this system")
+}
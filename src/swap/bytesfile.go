@@ -0,0 +1,49 @@
+/*
+This file implements -from-file and -to-file, which take the bytes to
+replace or their replacement from a file, for those too long to be
+practical as arguments.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	ba "bytearray"
+	"flag"
+	"fmt"
+	"io/ioutil"
+)
+
+//// TYPE bytesFileFlag ////
+
+// A flag.Value that sets target to the whole of the file named.
+type bytesFileFlag struct {
+	target *ba.ByteArray
+}
+
+func (bytesFileFlag) String() string {
+	return ""
+}
+
+func (f bytesFileFlag) Set(value string) error {
+	data, err := ioutil.ReadFile(value)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("%s is empty", value)
+	}
+	*f.target = data
+	return nil
+}
+
+//// FUNCTIONS ////
+
+// Registers -from-file and -to-file, which must be done before the flags
+// are parsed.
+func registerFileFlags() {
+	flag.Var(bytesFileFlag{&fromPattern.Bytes}, "from-file", "bytes to replace, given as the whole of this file")
+	flag.Var(bytesFileFlag{&toBytes}, "to-file", "replacement bytes, given as the whole of this file")
+}
@@ -0,0 +1,82 @@
+/*
+This file implements swap's -checksum, which records the SHA-256 of each
+file before and after it is altered (or, with -checksum-regions, of just
+the regions the replacements cover) to give a verifiable record of the
+edit.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"patch"
+)
+
+var checksum *bool = flag.Bool("checksum", false, "print the SHA-256 of each file before and after it is altered")
+var checksumRegions *bool = flag.Bool("checksum-regions", false, "with -checksum, only take the SHA-256 of the regions the replacements cover, one after another in offset order")
+
+// The checksums of a file before and after it was altered.
+type fileChecksums struct {
+	before, after string
+}
+
+// the checksums taken of each file altered, by path
+var checksums = make(map[string]fileChecksums)
+
+//// FUNCTIONS ////
+
+// Calls apply, which alters the file at path according to plan (nil if
+// the plan is not known), and returns what it returns. With -checksum the
+// file is checksummed before and after, and both are printed.
+func checksummed(path string, plan *patch.Plan, apply func() ([]uint64, []patch.Mismatch, error)) (applied []uint64, mismatches []patch.Mismatch, err error) {
+	if !*checksum {
+		return apply()
+	}
+
+	var sums fileChecksums
+	if sums.before, err = checksumFile(path, plan); err != nil {
+		return
+	}
+	if applied, mismatches, err = apply(); err != nil {
+		return
+	}
+	if sums.after, err = checksumFile(path, plan); err != nil {
+		return
+	}
+	checksums[path] = sums
+	if textStatus() {
+		fmt.Printf("%s: sha256 %s before, %s after\n", path, sums.before, sums.after)
+	}
+	return
+}
+
+// Returns the SHA-256, in hexadecimal, of the file at path or, with
+// -checksum-regions, of the regions plan's edits cover.
+func checksumFile(path string, plan *patch.Plan) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if *checksumRegions && plan != nil {
+		for _, edit := range plan.Edits {
+			region := io.NewSectionReader(f, int64(edit.Offset), int64(len(edit.Replacement)))
+			if _, err = io.Copy(h, region); err != nil {
+				return "", err
+			}
+		}
+	} else if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
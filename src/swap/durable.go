@@ -0,0 +1,96 @@
+/*
+This file implements the flags that make swap's writes durable, for
+patching boot sectors, firmware, and the like: -fsync, which has the
+writes reach the storage device (all of the file's state, or with
+-fsync=data only what is needed to read the data back), and -direct,
+which with -in-place writes around the system's cache altogether.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// the ways -fsync may have writes made durable
+const (
+	sync_none = ""
+	sync_full = "full" // fsync: the data and all of the file's metadata
+	sync_data = "data" // fdatasync: the data and what is needed to read it
+)
+
+// the size and alignment of each transfer made with -direct, where the
+// file is not a block device with a logical block size of its own
+const direct_block_size = 4096
+
+var direct *bool = flag.Bool("direct", false, "with -in-place, write around the system's cache (O_DIRECT), in whole aligned blocks; combine with -fsync to have the device's own cache flushed as well")
+
+// how -fsync has writes made durable
+var syncMode syncFlag
+
+//// TYPE syncFlag ////
+
+// A flag.Value for -fsync, which given alone means full; it may also be
+// given as -fsync=data or -fsync=false.
+type syncFlag string
+
+func (s *syncFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return string(*s)
+}
+
+func (s *syncFlag) Set(value string) error {
+	switch value {
+	case "true", sync_full:
+		*s = sync_full
+	case sync_data:
+		*s = sync_data
+	case "false", "none":
+		*s = sync_none
+	default:
+		return fmt.Errorf("%q is not full, data, or false", value)
+	}
+	return nil
+}
+
+func (*syncFlag) IsBoolFlag() bool {
+	return true
+}
+
+//// FUNCTIONS ////
+
+// Has the writes made to f reach its storage device, as -fsync asks.
+func syncFile(f *os.File) error {
+	switch syncMode {
+	case sync_full:
+		return f.Sync()
+	case sync_data:
+		return datasync(f)
+	}
+	return nil
+}
+
+// Has the renaming of a rewritten file at path reach its storage device,
+// as -fsync asks; the new file's data was synced before it was renamed.
+func syncRewritten(path string) error {
+	if syncMode == sync_none {
+		return nil
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err = dir.Sync(); err != nil && !isUnsupportedSync(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+/*
+This file implements -fsync=data and -direct on Linux.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Has the data written to f reach its storage device, without its
+// metadata beyond what is needed to read the data back.
+func datasync(f *os.File) error {
+	return syscall.Fdatasync(int(f.Fd()))
+}
+
+// Opens the file at path for reading and writing around the system's
+// cache.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|syscall.O_DIRECT, 0)
+}
+
+// Is err that of a file system on which directories cannot be synced?
+func isUnsupportedSync(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || os.IsPermission(err)
+}
@@ -0,0 +1,35 @@
+/*
+This file includes tests for the durable writes of swap on Linux.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsUnsupportedSync(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EINVAL, true},
+		{&os.PathError{Op: "sync", Path: "dir", Err: syscall.EINVAL}, true},
+		{&os.PathError{Op: "sync", Path: "dir", Err: syscall.EACCES}, true},
+		{&os.PathError{Op: "sync", Path: "dir", Err: syscall.EIO}, false},
+		{errors.New("other"), false},
+	}
+	for _, c := range cases {
+		if got := isUnsupportedSync(c.err); got != c.want {
+			t.Error(fmt.Sprintf("isUnsupportedSync(%v) = %v; expected %v", c.err, got, c.want))
+		}
+	}
+}
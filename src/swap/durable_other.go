@@ -0,0 +1,34 @@
+//go:build !linux
+// +build !linux
+
+/*
+This file stands in for -fsync=data and -direct on systems where they
+are not implemented.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Has the data written to f reach its storage device, which here also
+// syncs its metadata.
+func datasync(f *os.File) error {
+	return f.Sync()
+}
+
+// Returns an error, as writing around the cache is not implemented.
+func openDirect(path string) (*os.File, error) {
+	return nil, errors.New("-direct is not supported on this system")
+}
+
+// Is err that of a file system (or system) on which directories cannot be
+// synced?
+func isUnsupportedSync(err error) bool {
+	return true
+}
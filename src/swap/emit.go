@@ -0,0 +1,86 @@
+/*
+This file implements swap's -emit-patch, which writes the changes that
+would be made as a patch, for review or to be applied elsewhere, rather
+than making them. A patch is a plan (see the patch package) in which every
+edit gives both the bytes expected and their replacement.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"flag"
+	"os"
+	"patch"
+)
+
+var emitFile *string = flag.String("emit-patch", "", "instead of altering files, write the changes that would be made (those whose bytes are as expected) to this file (\"-\" for stdout) as a patch, which -apply-patch applies")
+
+// where -emit-patch's patch goes, if anywhere
+var emitted *os.File
+
+//// FUNCTIONS ////
+
+// Returns the file named by a flag such as -emit-patch, created anew: nil
+// if name is empty, or stdout if it is "-".
+func createOutput(name string) (*os.File, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "-":
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// Closes f, as returned by createOutput.
+func closeOutput(f *os.File) error {
+	if f != nil && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
+
+// Writes the edits of plan whose bytes are found as expected in the file
+// at path to the patch. Returns the offsets written to the patch and the
+// mismatches.
+func emitPlan(path string, plan *patch.Plan) (emittedOffsets []uint64, mismatches []patch.Mismatch, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fp := &patch.FilePlan{Path: path, Size: -1}
+	if info.Mode().IsRegular() {
+		fp.Size = info.Size()
+	}
+	for _, edit := range plan.Edits {
+		var m []patch.Mismatch
+		if m, err = (&patch.Plan{Edits: []patch.Edit{edit}}).Verify(f); err != nil {
+			return
+		}
+		if len(m) != 0 {
+			mismatches = append(mismatches, m...)
+			continue
+		}
+
+		// what is there is what the patch expects, wildcards and all
+		expected := make([]byte, len(edit.Replacement))
+		if _, err = f.ReadAt(expected, int64(edit.Offset)); err != nil {
+			return
+		}
+		fp.Edits = append(fp.Edits, patch.FileEdit{Offset: edit.Offset, Expected: expected, Replacement: edit.Replacement})
+		emittedOffsets = append(emittedOffsets, edit.Offset)
+	}
+	if len(fp.Edits) != 0 {
+		err = patch.WriteFilePlan(emitted, fp)
+	}
+	return
+}
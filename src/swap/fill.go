@@ -0,0 +1,117 @@
+/*
+This file implements the filling of whole byte ranges with one repeated
+byte (-fill and -range), e.g., to zero out a region holding credentials.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"patch"
+	"strings"
+)
+
+// the largest single write made when filling a range
+const fill_chunk_size = 1024 * 1024
+
+// A range of offsets to fill, from start up to but not including end.
+type fillRange struct {
+	start, end uint64
+}
+
+// the ranges given by -range, in the order given
+var fillRanges rangesFlag
+
+//// TYPE rangesFlag ////
+
+// A flag.Value that adds a range, written START:END, each time the flag is
+// given. The offsets are read by parseRanges once all the flags are
+// parsed, since -hex may follow.
+type rangesFlag []string
+
+func (rangesFlag) String() string {
+	return ""
+}
+
+func (r *rangesFlag) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return errors.New("a range must be written START:END")
+	}
+	*r = append(*r, value)
+	return nil
+}
+
+//// FUNCTIONS ////
+
+// Is a fill (-fill) requested?
+func filling() bool {
+	return *fillByte != ""
+}
+
+// Returns the ranges written in values as START:END, whose offsets are
+// read as by parseOffset.
+func parseRanges(values []string) ([]fillRange, error) {
+	ranges := make([]fillRange, 0, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 2)
+		start, err := parseOffset(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("trying to parse \"%s\" as the start of the range %s; got %s", parts[0], value, err)
+		}
+		end, err := parseOffset(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("trying to parse \"%s\" as the end of the range %s; got %s", parts[1], value, err)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("the range %s is empty; END must be greater than START", value)
+		}
+		ranges = append(ranges, fillRange{start, end})
+	}
+	return ranges, nil
+}
+
+// Returns the byte given by -fill, which is read as an offset is.
+func parseFill(s string) (byte, error) {
+	v, err := parseOffset(s)
+	if err != nil {
+		return 0, err
+	}
+	if v > 0xff {
+		return 0, fmt.Errorf("%s does not fit in a byte", s)
+	}
+	return byte(v), nil
+}
+
+// Returns a plan that writes fill over each of ranges in the file at path,
+// in writes of at most fill_chunk_size bytes. Every range must lie within
+// the file, so that a mistyped range cannot extend it.
+func buildFillPlan(path string, fill byte, ranges []fillRange) (*patch.Plan, error) {
+	fileBytes, err := fileSize(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the writes all share one chunk's worth of the byte
+	chunk := bytes.Repeat([]byte{fill}, fill_chunk_size)
+	size := uint64(fileBytes)
+	plan := &patch.Plan{}
+	for _, r := range ranges {
+		if r.end > size {
+			return nil, fmt.Errorf("the range %d:%d extends beyond the end of the file, at %d", r.start, r.end, size)
+		}
+		for offset := r.start; offset < r.end; offset += fill_chunk_size {
+			count := r.end - offset
+			if count > fill_chunk_size {
+				count = fill_chunk_size
+			}
+			plan.Edits = append(plan.Edits, patch.Edit{Offset: offset, Replacement: chunk[:count]})
+		}
+	}
+	plan.Sort()
+	return plan, nil
+}
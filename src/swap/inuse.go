@@ -0,0 +1,49 @@
+/*
+This file implements swap's -check-in-use, which refuses to alter a file
+that another process has open or mapped (e.g., a running executable),
+since the results of patching one are confusing at best.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var checkInUse *bool = flag.Bool("check-in-use", false, "refuse to alter a file that another process has open or mapped; with -force, only warn")
+
+//// FUNCTIONS ////
+
+// Returns an error if -check-in-use was given and another process has the
+// file at path open or mapped. With -force, a warning is given instead.
+func checkNotInUse(path string) error {
+	if !*checkInUse {
+		return nil
+	}
+
+	pids, err := processesUsing(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: cannot tell whether %s is in use; %s\n", path, err)
+		return nil
+	}
+	if len(pids) == 0 {
+		return nil
+	}
+
+	list := make([]string, len(pids))
+	for i, pid := range pids {
+		list[i] = strconv.Itoa(pid)
+	}
+	if *force {
+		fmt.Fprintf(os.Stderr, "warning: %s is in use by process %s\n", path, strings.Join(list, ", "))
+		return nil
+	}
+	return fmt.Errorf("it is in use by process %s; use -force to alter it anyway", strings.Join(list, ", "))
+}
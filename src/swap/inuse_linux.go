@@ -0,0 +1,89 @@
+//go:build linux
+// +build linux
+
+/*
+This file implements the finding of the processes using a file on Linux,
+from each process's open files and mappings under /proc.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Returns the IDs of the processes, other than this one, that have the
+// file at path open or mapped. Processes whose details may not be read
+// (e.g., those of other users) are passed over.
+func processesUsing(path string) ([]int, error) {
+	var target syscall.Stat_t
+	if err := syscall.Stat(path, &target); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		if hasOpen(dir, &target) || hasMapped(dir, &target) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// Does the process whose /proc directory is dir have the file target open?
+func hasOpen(dir string, target *syscall.Stat_t) bool {
+	fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		var st syscall.Stat_t
+		if syscall.Stat(filepath.Join(dir, "fd", fd.Name()), &st) == nil && st.Dev == target.Dev && st.Ino == target.Ino {
+			return true
+		}
+	}
+	return false
+}
+
+// Does the process whose /proc directory is dir have the file target
+// mapped? Each line of its maps gives the device (as major:minor in hex)
+// and inode of what is mapped.
+func hasMapped(dir string, target *syscall.Stat_t) bool {
+	f, err := os.Open(filepath.Join(dir, "maps"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	dev := uint64(target.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	want := fmt.Sprintf("%02x:%02x %d", major, minor, target.Ino)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 5 && fields[3]+" "+fields[4] == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+/*
+This file stands in for the finding of the processes using a file on
+systems where it is not implemented.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+)
+
+// Returns an error, as it is not known how to tell on this system.
+func processesUsing(path string) ([]int, error) {
+	return nil, errors.New("this is not supported on this system")
+}
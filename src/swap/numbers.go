@@ -0,0 +1,108 @@
+/*
+This file implements the flags that give the bytes to replace or their
+replacement as an integer of a given size and byte order (e.g., -to-u32le
+1234), so that numeric fields can be patched without ordering the bytes
+by hand.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	ba "bytearray"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//// TYPE numberFlag ////
+
+// A flag.Value that sets target to the encoding of an integer of size
+// bytes in the byte order given.
+type numberFlag struct {
+	target *ba.ByteArray
+	size   int
+	order  binary.ByteOrder
+}
+
+func (numberFlag) String() string {
+	return ""
+}
+
+func (f numberFlag) Set(value string) error {
+	bits := 8 * f.size
+	var v uint64
+	var err error
+	if strings.HasPrefix(value, "-") {
+		// a negative value is encoded in two's complement
+		var signed int64
+		signed, err = strconv.ParseInt(value, 0, bits)
+		v = uint64(signed)
+	} else {
+		v, err = strconv.ParseUint(value, 0, bits)
+	}
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 8)
+	switch f.size {
+	case 2:
+		f.order.PutUint16(b, uint16(v))
+	case 4:
+		f.order.PutUint32(b, uint32(v))
+	case 8:
+		f.order.PutUint64(b, v)
+	}
+	*f.target = b[:f.size]
+	return nil
+}
+
+//// FUNCTIONS ////
+
+// Returns an error if more than one flag gives the bytes to replace (-from,
+// -fromb, -from-file, -from-u16le, ...), or more than one their
+// replacement. Which flags were given is checked once all are parsed, so
+// the order they were given in does not matter.
+func checkByteFlags() error {
+	var from, to []string
+	flag.Visit(func(f *flag.Flag) {
+		switch {
+		case f.Name == "from" || f.Name == "fromb" || strings.HasPrefix(f.Name, "from-"):
+			from = append(from, "-"+f.Name)
+		case f.Name == "to" || f.Name == "tob" || strings.HasPrefix(f.Name, "to-"):
+			to = append(to, "-"+f.Name)
+		}
+	})
+	for _, given := range [][]string{from, to} {
+		if len(given) > 1 {
+			return fmt.Errorf("specified both %s and %s", given[0], given[1])
+		}
+	}
+	return nil
+}
+
+// Registers -from-u16le, -to-u64be, and the rest, which must be done
+// before the flags are parsed.
+func registerNumberFlags() {
+	orders := []struct {
+		name  string
+		order binary.ByteOrder
+		desc  string
+	}{
+		{"le", binary.LittleEndian, "little-endian"},
+		{"be", binary.BigEndian, "big-endian"},
+	}
+	for _, size := range []int{2, 4, 8} {
+		for _, o := range orders {
+			suffix := fmt.Sprintf("u%d%s", 8*size, o.name)
+			kind := fmt.Sprintf("a %d-bit %s integer, e.g., 1234, 0xbeef, or -1", 8*size, o.desc)
+			flag.Var(numberFlag{&fromPattern.Bytes, size, o.order}, "from-"+suffix, "bytes to replace, given as "+kind)
+			flag.Var(numberFlag{&toBytes, size, o.order}, "to-"+suffix, "replacement bytes, given as "+kind)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+/*
+This file implements the reading of the offsets at which swap makes its
+replacements, whether from the command line or the standard input.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Returns the offset written as s: in hexadecimal if it begins with 0x or
+// -hex was given, otherwise in decimal. A decimal offset containing one of
+// the digits a to f is refused rather than guessed to be hexadecimal, as
+// the rest of a list of hexadecimal offsets would be read as decimal.
+func parseOffset(s string) (uint64, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return strconv.ParseUint(s[2:], 16, 64)
+	}
+	if *hexOffsets {
+		return strconv.ParseUint(s, 16, 64)
+	}
+	if strings.ContainsAny(s, "abcdefABCDEF") {
+		return 0, errors.New("hexadecimal digits in a decimal offset; write it with 0x, or give -hex")
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// Returns the offsets read from r, which are separated by white space
+// (e.g., one per line).
+func readOffsets(r io.Reader) ([]uint64, error) {
+	var offsets []uint64
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		v, err := parseOffset(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("trying to parse \"%s\" as an offset; got %s", scanner.Text(), err)
+		}
+		offsets = append(offsets, v)
+	}
+	return offsets, scanner.Err()
+}
+
+// Is f a terminal (rather than, e.g., a pipe)?
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
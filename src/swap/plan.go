@@ -0,0 +1,317 @@
+/*
+This file implements swap's -plan, which applies the replacements listed
+in a plan file, as written by sift -swap, to many files at once. Both
+sift's JSON plans and its original format (a quoted path followed by
+offsets, one file per line) are read.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bufio"
+	ba "bytearray"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"myerr"
+	"os"
+	"patch"
+	"strings"
+	"substr"
+)
+
+// Returns the plans for each file in the plan file at path ("-" for the
+// standard input).
+func readPlanFile(path string) ([]*patch.FilePlan, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return patch.ReadFilePlans(bytes.NewReader(data))
+	}
+	return readPlanV1(bytes.NewReader(data))
+}
+
+// Returns the plans in r, which is in sift's original -swap format: each
+// line holds a path in double quotes followed by offsets. An offset may
+// be followed by the bytes expected there and their replacement, in hex,
+// as OFFSET:EXPECTED:REPLACEMENT; either may be left empty to take -from
+// or -to. An offset may also be given as NAME+OFFSET, relative to the
+// first occurrence in the file of an anchor defined on the line as
+// NAME=HEX (e.g., "header=7f454c46 header+0x40"), so that the plan still
+// applies where a rebuild has moved things.
+func readPlanV1(r io.Reader) ([]*patch.FilePlan, error) {
+	var plans []*patch.FilePlan
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		// sift does not escape the path, so it ends at the last quote
+		end := strings.LastIndex(text, "\"")
+		if text[0] != '"' || end == 0 {
+			return nil, fmt.Errorf("line %d does not begin with a quoted path", line)
+		}
+		fp := &patch.FilePlan{Version: patch.PlanVersion, Path: text[1:end], Size: -1}
+		for _, field := range strings.Fields(text[end+1:]) {
+			if name, needle, ok := strings.Cut(field, "="); ok {
+				if err := addAnchor(fp, name, needle); err != nil {
+					return nil, fmt.Errorf("line %d: %s", line, err)
+				}
+				continue
+			}
+			edit, err := parseEdit(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			fp.Edits = append(fp.Edits, edit)
+		}
+		plans = append(plans, fp)
+	}
+	return plans, scanner.Err()
+}
+
+// Adds to fp the anchor written NAME=HEX in a plan of the original format.
+func addAnchor(fp *patch.FilePlan, name, needle string) error {
+	if !validAnchorName(name) {
+		return fmt.Errorf("\"%s\" is not a valid anchor name; it must be a letter or _ followed by letters, digits, or _", name)
+	}
+	if _, ok := fp.Anchors[name]; ok {
+		return fmt.Errorf("the anchor %s is defined more than once", name)
+	}
+	var b ba.ByteArray
+	if err := b.Set(needle); err != nil {
+		return fmt.Errorf("trying to parse \"%s\" as the bytes of the anchor %s; got %s", needle, name, err)
+	}
+	if len(b) == 0 {
+		return fmt.Errorf("the anchor %s is empty", name)
+	}
+	if fp.Anchors == nil {
+		fp.Anchors = make(map[string]patch.Hex)
+	}
+	fp.Anchors[name] = patch.Hex(b)
+	return nil
+}
+
+// Is name usable as an anchor's?
+func validAnchorName(name string) bool {
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Returns the edit written as field in a plan of the original format:
+// OFFSET, or OFFSET:EXPECTED:REPLACEMENT, where OFFSET may be NAME+OFFSET.
+func parseEdit(field string) (edit patch.FileEdit, err error) {
+	parts := strings.Split(field, ":")
+	if len(parts) != 1 && len(parts) != 3 {
+		return edit, fmt.Errorf("\"%s\" is neither an offset nor OFFSET:EXPECTED:REPLACEMENT", field)
+	}
+	if name, relative, ok := strings.Cut(parts[0], "+"); ok {
+		if !validAnchorName(name) {
+			return edit, fmt.Errorf("\"%s\" is not a valid anchor name in \"%s\"", name, parts[0])
+		}
+		edit.Anchor, parts[0] = name, relative
+	}
+	if edit.Offset, err = parseOffset(parts[0]); err != nil {
+		return edit, fmt.Errorf("trying to parse \"%s\" as an offset; got %s", parts[0], err)
+	}
+	if len(parts) == 3 {
+		var expected, replacement ba.ByteArray
+		if err = expected.Set(parts[1]); err != nil {
+			return edit, fmt.Errorf("trying to parse \"%s\" as the bytes expected at offset %d; got %s", parts[1], edit.Offset, err)
+		}
+		if err = replacement.Set(parts[2]); err != nil {
+			return edit, fmt.Errorf("trying to parse \"%s\" as the replacement at offset %d; got %s", parts[2], edit.Offset, err)
+		}
+		edit.Expected, edit.Replacement = patch.Hex(expected), patch.Hex(replacement)
+	}
+	return edit, nil
+}
+
+// Returns the plan for the changes fp lists. An edit that does not give the
+// bytes it expects or its replacement takes them from -from and -to (or
+// -template). Offsets relative to an anchor are resolved by searching the
+// file for it.
+func buildPlan(fp *patch.FilePlan) (*patch.Plan, error) {
+	fp, err := patch.ResolveAnchors(fp, func(needle []byte) (uint64, bool, error) {
+		return findAnchor(fp.Path, needle)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if templating() {
+		// each edit without a replacement gets its own
+		templated := *fp
+		templated.Edits = append([]patch.FileEdit(nil), fp.Edits...)
+		for i, e := range templated.Edits {
+			if len(e.Replacement) != 0 {
+				continue
+			}
+			if templated.Edits[i].Replacement, err = templateReplacement(e.Offset); err != nil {
+				return nil, err
+			}
+		}
+		fp = &templated
+	}
+	return patch.BuildPlan(fp, patch.Edit{Expected: fromBytes, Replacement: toBytes, Mask: fromMask})
+}
+
+// Returns an error if the file fp is for is no longer as it was when the
+// plan was made, going by its size, modification time, and checksum, as
+// far as the plan gives them.
+func checkUnchanged(fp *patch.FilePlan) error {
+	if fp.Size < 0 && fp.ModTime == nil && fp.SHA256 == "" {
+		return nil
+	}
+	info, err := os.Stat(fp.Path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fp.Size >= 0 && info.Size() != fp.Size:
+		return fmt.Errorf("is %d bytes, but was %d when the plan was made; use -force to apply it anyway", info.Size(), fp.Size)
+	case fp.ModTime != nil && !info.ModTime().Equal(*fp.ModTime):
+		return fmt.Errorf("was last modified at %s, not at %s as when the plan was made; use -force to apply it anyway", info.ModTime(), *fp.ModTime)
+	case fp.SHA256 != "":
+		var sum string
+		if sum, err = checksumFile(fp.Path, nil); err != nil {
+			return err
+		}
+		if sum != fp.SHA256 {
+			return fmt.Errorf("has a SHA-256 of %s, but had %s when the plan was made; use -force to apply it anyway", sum, fp.SHA256)
+		}
+	}
+	return nil
+}
+
+// Applies every file's plan from the plan file at path, reporting the
+// outcome for each. Returns the status to exit with, as for applyPlans.
+func applyPlanFile(path string) int {
+	plans, err := readPlanFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not read plan %s; %s\n", path, err)
+		return status_fatal_error
+	}
+	return applyPlans(plans)
+}
+
+// Applies the patch at path, as written by -emit-patch, in which every
+// edit gives the bytes it expects and their replacement. If target is not
+// empty, the patch, which must then be for a single file, is applied to it
+// instead. Returns the status to exit with, as for applyPlans.
+func applyPatchFile(path, target string) int {
+	plans, err := readPlanFile(path)
+	if err == nil {
+		err = checkPatch(plans, target)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not read patch %s; %s\n", path, err)
+		return status_fatal_error
+	}
+	if target != "" {
+		plans[0].Path = target
+	}
+	return applyPlans(plans)
+}
+
+// Returns an error if plans are not a patch: one in which every edit gives
+// both the bytes it expects and their replacement. If target is not empty,
+// the plans must be for a single file.
+func checkPatch(plans []*patch.FilePlan, target string) error {
+	if target != "" && len(plans) != 1 {
+		return fmt.Errorf("it is for %d files, so cannot be applied to %s alone", len(plans), target)
+	}
+	for _, fp := range plans {
+		for _, e := range fp.Edits {
+			if len(e.Expected) == 0 || len(e.Replacement) == 0 {
+				return fmt.Errorf("the edit at offset %d of %s does not give both the bytes it expects and their replacement", e.Offset, fp.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// Applies each of plans, reporting the outcome for each file. Returns the
+// status to exit with: status_applied if all were applied in full,
+// status_skipped if some replacements were skipped, status_fatal_error if
+// any file could not be altered, and status_nothing_to_do if the plans ask
+// for no replacements.
+func applyPlans(plans []*patch.FilePlan) int {
+	count := 0
+	for _, fp := range plans {
+		count += len(fp.Edits)
+	}
+	if err := checkReplacementCount(count); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return status_fatal_error
+	}
+
+	status := status_nothing_to_do
+	for _, fp := range plans {
+		if len(fp.Edits) == 0 {
+			continue
+		}
+		p, err := buildPlan(fp)
+		if err == nil && !*force {
+			err = checkUnchanged(fp)
+		} else if err == nil {
+			myerr.Debug("%s: not checked for changes since the plan was made (-force)", fp.Path)
+		}
+		if err != nil {
+			noteResult(fp.Path, nil, nil, err)
+		} else {
+			var applied []uint64
+			var mismatches []patch.Mismatch
+			applied, mismatches, err = applyPlan(fp.Path, p)
+			noteResult(fp.Path, applied, mismatches, err)
+			if err == nil {
+				if !*quiet && textStatus() {
+					fmt.Printf("%s: %d replaced, %d skipped\n", fp.Path, len(applied), len(mismatches))
+				}
+				if len(mismatches) != 0 && status != status_fatal_error {
+					status = status_skipped
+				} else if status == status_nothing_to_do {
+					status = status_applied
+				}
+				continue
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: error -- %s\n", fp.Path, err)
+		status = status_fatal_error
+	}
+	return status
+}
+
+// Returns the offset of the first occurrence of needle in the file at path,
+// and whether there is one.
+func findAnchor(path string, needle []byte) (uint64, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	found, offset, err := substr.Index(substr.NewHaystackFile(f), substr.NewNeedleBytes(needle))
+	if err == nil && found {
+		myerr.Debug("%s: anchor %x found at offset %d", path, needle, offset)
+	}
+	return offset, found, err
+}
@@ -0,0 +1,106 @@
+/*
+This file implements the reporting of progress to stderr while swap copies
+or rewrites a file, which for a large file can take many minutes.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	progress_threshold = 64 * 1024 * 1024 // the smallest file progress is shown for by default
+	progress_interval  = 250 * time.Millisecond
+)
+
+var showProgress *bool = flag.Bool("progress", false, "show the progress of copying each file to stderr; by default it is shown for files of 64MB or more when stderr is a terminal")
+
+//// TYPE progress ////
+
+// The progress of copying one file, shown on stderr as it is updated.
+type progress struct {
+	path  string
+	start time.Time
+	last  time.Time
+	shown bool
+}
+
+// Returns a progress for copying the file at path.
+func newProgress(path string) *progress {
+	now := time.Now()
+	return &progress{path: path, start: now, last: now}
+}
+
+// Is progress to be shown for a file of size bytes (-1 if not known)?
+func wantProgress(size int64) bool {
+	return *showProgress || (!*quiet && size >= progress_threshold && isTerminal(os.Stderr))
+}
+
+// Notes that done of total bytes (-1 if not known) have been copied,
+// showing as much at most every progress_interval.
+func (p *progress) update(done, total int64) {
+	now := time.Now()
+	if !wantProgress(total) || now.Sub(p.last) < progress_interval {
+		return
+	}
+	p.last = now
+
+	// a terminal shows a single line that is rewritten in place
+	end := "\n"
+	if isTerminal(os.Stderr) {
+		end = "\r"
+	}
+	if total <= 0 {
+		fmt.Fprintf(os.Stderr, "%s: %s copied%s", p.path, megabytes(done), end)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: %s of %s (%d%%), %s left%s", p.path, megabytes(done), megabytes(total), done*100/total, p.remaining(done, total), end)
+	}
+	p.shown = true
+}
+
+// Returns the estimated time left to copy total bytes, given that done
+// have been copied so far.
+func (p *progress) remaining(done, total int64) time.Duration {
+	if done == 0 {
+		return 0
+	}
+	elapsed := time.Since(p.start)
+	return (time.Duration(float64(elapsed) * float64(total-done) / float64(done))).Round(time.Second)
+}
+
+// Ends the line of progress on a terminal, if any was shown.
+func (p *progress) finish() {
+	if p.shown && isTerminal(os.Stderr) {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// Returns n bytes written in megabytes.
+func megabytes(n int64) string {
+	return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+}
+
+//// TYPE progressReader ////
+
+// A reader that updates a progress, of total bytes, as r is read.
+type progressReader struct {
+	r     io.Reader
+	done  int64
+	total int64
+	p     *progress
+}
+
+func (pr *progressReader) Read(data []byte) (int, error) {
+	n, err := pr.r.Read(data)
+	pr.done += int64(n)
+	pr.p.update(pr.done, pr.total)
+	return n, err
+}
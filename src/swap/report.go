@@ -0,0 +1,77 @@
+/*
+This file implements swap's -report, a JSON account of what was done to
+each file (the offsets replaced, those skipped, and any error), and
+-skipped-report, which lists just the offsets skipped, for tools that
+drive swap.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"myerr"
+	"os"
+	"patch"
+)
+
+var reportFile *string = flag.String("report", "", "write the outcome for each file, as JSON with one object per line, to this file (\"-\" for stdout, which then carries nothing else)")
+
+var skippedFile *string = flag.String("skipped-report", "", "write each offset skipped because the bytes there were not those expected, as JSON giving the file, offset, and bytes expected and found, one object per line, to this file (\"-\" for stdout)")
+
+// where -report's results and -skipped-report's offsets go, if anywhere
+var report, skipped *os.File
+
+// An offset skipped, as written by -skipped-report.
+type skippedOffset struct {
+	Path string `json:"path"`
+	patch.SkippedEdit
+}
+
+//// FUNCTIONS ////
+
+// Is the outcome for each file to be written to stdout as text? Not if a
+// report or a patch takes its place.
+func textStatus() bool {
+	for _, o := range outputs() {
+		if *o.file == os.Stdout {
+			return false
+		}
+	}
+	return true
+}
+
+// Adds the outcome of altering the file at path to the reports, if any
+// were requested.
+func noteResult(path string, applied []uint64, mismatches []patch.Mismatch, err error) {
+	if myerr.Enabled(myerr.LevelDebug) {
+		for _, offset := range applied {
+			myerr.Debug("%s: replaced at offset %d", path, offset)
+		}
+		for _, m := range mismatches {
+			myerr.Debug("%s: skipped offset %d; expected %x, found %x", path, m.Offset, m.Expected, m.Found)
+		}
+	}
+	if skipped != nil {
+		enc := json.NewEncoder(skipped)
+		for _, m := range mismatches {
+			if e := enc.Encode(skippedOffset{path, patch.SkippedEdit{Offset: m.Offset, Expected: m.Expected, Found: m.Found}}); e != nil {
+				myerr.MyError("error: could not write the skipped-offset report; %s", e)
+				break
+			}
+		}
+	}
+	if report == nil {
+		return
+	}
+	result := patch.NewFileResult(path, applied, mismatches, err)
+	if sums, ok := checksums[path]; ok {
+		result.SHA256Before, result.SHA256After = sums.before, sums.after
+	}
+	if e := patch.WriteFileResult(report, result); e != nil {
+		myerr.MyError("error: could not write the report; %s", e)
+	}
+}
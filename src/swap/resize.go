@@ -0,0 +1,97 @@
+/*
+This file implements replacements of a different size from the bytes
+they replace (-from and -to of different lengths). The file is rewritten
+through the library's streaming replace, so every byte after each
+replacement moves by the difference in size.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"fileutil"
+	"fmt"
+	"io"
+	"os"
+	"patch"
+	"sort"
+	"substr"
+)
+
+// Are -from and -to of different sizes?
+func resizing() bool {
+	return len(fromBytes) != 0 && !templating() && len(fromBytes) != len(toBytes)
+}
+
+// Replaces fromBytes with toBytes at each of offsets (within the original
+// file) in the file at path, which is rewritten with the original kept as
+// a backup. Returns the offsets replaced and those at which fromBytes was
+// not found. Offsets whose bytes would overlap are refused, and if nothing
+// is replaced the file is left as it was.
+func applyResized(path string, offsets []uint64) (applied []uint64, mismatches []patch.Mismatch, err error) {
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1]+uint64(len(fromBytes)) {
+			return nil, nil, fmt.Errorf("the %d bytes replaced at offsets %d and %d would overlap", len(fromBytes), offsets[i-1], offsets[i])
+		}
+	}
+
+	wanted := make(map[uint64]bool, len(offsets))
+	for _, offset := range offsets {
+		wanted[offset] = true
+	}
+
+	err = rewriteMatches(path, func(offset uint64, match []byte) []byte {
+		if !wanted[offset] {
+			return match
+		}
+		delete(wanted, offset)
+		applied = append(applied, offset)
+		return toBytes
+	}, func(r io.ReaderAt) error {
+		// what remains was not found where expected
+		for _, offset := range offsets {
+			if !wanted[offset] {
+				continue
+			}
+			found := make([]byte, len(fromBytes))
+			count, _ := r.ReadAt(found, int64(offset))
+			mismatches = append(mismatches, patch.Mismatch{Offset: offset, Expected: fromBytes, Found: found[:count]})
+		}
+		if len(applied) == 0 {
+			return errNoMatches
+		}
+		return nil
+	})
+	if err == errNoMatches {
+		err = nil
+	}
+	return
+}
+
+// Rewrites the file at path in a single pass, with the original kept as a
+// backup, replacing each match of fromBytes with what replace returns for
+// it (as for substr.ReplaceFunc). finish is then called with the original;
+// if it returns an error, the file is left as it was.
+func rewriteMatches(path string, replace func(offset uint64, match []byte) []byte, finish func(r io.ReaderAt) error) error {
+	backupName, err := fileutil.AtomicRewriteBackup(path, func(w io.Writer, r io.Reader) error {
+		total := int64(-1)
+		if info, e := r.(*os.File).Stat(); e == nil && info.Mode().IsRegular() {
+			total = info.Size()
+		}
+		p := newProgress(path)
+		e := substr.ReplaceFunc(w, &progressReader{r, 0, total, p}, substr.NewNeedleBytes(fromBytes), replace)
+		p.finish()
+		if e != nil {
+			return e
+		}
+		return finish(r.(io.ReaderAt))
+	})
+	if err == nil {
+		manageBackup(path, backupName)
+		err = syncRewritten(path)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+/*
+This file implements swap's -reverse-patch, which writes, as the changes
+are made, the patch that undoes them, so that they can be rolled back with
+-apply-patch.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"flag"
+	"os"
+	"patch"
+)
+
+var reverseFile *string = flag.String("reverse-patch", "", "as the changes are made, write the patch that undoes them to this file (\"-\" for stdout), for -apply-patch")
+
+// where -reverse-patch's patch goes, if anywhere
+var reversed *os.File
+
+//// FUNCTIONS ////
+
+// Returns the bytes of the file at path that each of plan's edits would
+// replace, in the order of its edits.
+func readOriginals(path string, plan *patch.Plan) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	originals := make([][]byte, len(plan.Edits))
+	for i, edit := range plan.Edits {
+		originals[i] = make([]byte, len(edit.Replacement))
+		count, _ := f.ReadAt(originals[i], int64(edit.Offset))
+		originals[i] = originals[i][:count]
+	}
+	return originals, nil
+}
+
+// Writes the patch that undoes those of plan's edits made at the offsets
+// applied in the file at path, given the bytes they replaced.
+func emitReverse(path string, plan *patch.Plan, originals [][]byte, applied []uint64) error {
+	fp := &patch.FilePlan{Path: path, Size: -1}
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		fp.Size = info.Size()
+	}
+
+	// the edits were applied in order, skipping those that did not match
+	next := 0
+	for i, edit := range plan.Edits {
+		if next == len(applied) || applied[next] != edit.Offset {
+			continue
+		}
+		next++
+		if len(originals[i]) != len(edit.Replacement) {
+			continue // it extended the file, which a patch cannot undo
+		}
+		fp.Edits = append(fp.Edits, patch.FileEdit{Offset: edit.Offset, Expected: edit.Replacement, Replacement: originals[i]})
+	}
+	if len(fp.Edits) == 0 {
+		return nil
+	}
+	return patch.WriteFilePlan(reversed, fp)
+}
@@ -0,0 +1,152 @@
+/*
+This file implements swap's -search, which finds the bytes to replace
+itself rather than being given their offsets, so that a file is searched
+and altered in one process, with no chance for it to change in between
+as when sift and swap are run one after the other. Where swap makes a
+copy, the search and the replacing are one pass over the file.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"patch"
+	"substr"
+)
+
+// keeps a file in which nothing was found from being rewritten
+var errNoMatches = errors.New("nothing to replace")
+
+var search *bool = flag.Bool("search", false, "replace every (non-overlapping) occurrence of -from or -fromb in each file given, rather than at given offsets")
+
+//// FUNCTIONS ////
+
+// Returns an error if the flags given may not be used with -search.
+func checkSearchFlags() error {
+	switch {
+	case len(fromBytes) == 0:
+		return errors.New("-search requires -from or -fromb, which is searched for")
+	case fromMask != nil:
+		return errors.New("-search cannot search for -fromb containing ?")
+	case *planFile != "":
+		return errors.New("specified both -search and -plan")
+	case flag.NArg() == 0:
+		return errors.New("must specify the files to search")
+	}
+	return nil
+}
+
+// Replaces the bytes searched for in each of paths, reporting the outcome
+// for each. Returns the status to exit with, as for applyPlans.
+func searchFiles(paths []string) int {
+	status := status_nothing_to_do
+	for _, path := range paths {
+		applied, err := searchFile(path)
+		noteResult(path, applied, nil, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error -- %s\n", path, err)
+			status = status_fatal_error
+			continue
+		}
+		if !*quiet && textStatus() {
+			fmt.Printf("%s: %d replaced\n", path, len(applied))
+		}
+		if len(applied) != 0 && status == status_nothing_to_do {
+			status = status_applied
+		}
+	}
+	return status
+}
+
+// Replaces the bytes searched for in the file at path. Returns the offsets
+// at which they were replaced.
+func searchFile(path string) ([]uint64, error) {
+	if *inPlace || emitted != nil || reversed != nil {
+		// these work from a plan, so the offsets must be known first
+		offsets, err := findOffsets(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(offsets) == 0 {
+			return nil, nil
+		}
+		if err = checkReplacementCount(len(offsets)); err != nil {
+			return nil, err
+		}
+		fp := &patch.FilePlan{Path: path}
+		for _, offset := range offsets {
+			fp.Edits = append(fp.Edits, patch.FileEdit{Offset: offset})
+		}
+		plan, err := buildPlan(fp)
+		if err != nil {
+			return nil, err
+		}
+		applied, _, err := applyPlan(path, plan)
+		return applied, err
+	}
+
+	var applied []uint64
+	_, _, err := checksummed(path, nil, func() ([]uint64, []patch.Mismatch, error) {
+		var failure error
+		err := rewriteMatches(path, func(offset uint64, match []byte) []byte {
+			if failure != nil {
+				return match
+			}
+			if *maxReplacements > 0 && len(applied) == *maxReplacements {
+				failure = fmt.Errorf("more locations would be altered than the %d allowed by -max-replacements; nothing was altered", *maxReplacements)
+				return match
+			}
+			replacement := []byte(toBytes)
+			if templating() {
+				if replacement, failure = templateReplacement(offset); failure != nil {
+					return match
+				}
+			}
+			applied = append(applied, offset)
+			return replacement
+		}, func(io.ReaderAt) error {
+			if failure == nil && len(applied) == 0 {
+				failure = errNoMatches
+			}
+			return failure
+		})
+		return applied, nil, err
+	})
+	if err == errNoMatches {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// Returns the offsets of the non-overlapping occurrences of fromBytes in
+// the file at path.
+func findOffsets(path string) ([]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var offsets []uint64
+	next := uint64(0)
+	for r := range substr.Indexes(substr.NewHaystackFile(f), substr.NewNeedleBytes(fromBytes)) {
+		if r.Error != nil {
+			err = r.Error
+			continue
+		}
+		if r.Offset >= next {
+			offsets = append(offsets, r.Offset)
+			next = r.Offset + uint64(len(fromBytes))
+		}
+	}
+	return offsets, err
+}
@@ -11,45 +11,46 @@ package main
 import (
 	ba "bytearray"
 	"errors"
+	"fileutil"
 	"flag"
 	"fmt"
 	"io"
 	"myerr"
 	"os"
+	"patch"
 	"sort"
-	"strconv"
 )
 
-const status_fatal_error = 1
-
-//// TYPE uint64Slice ////
-
-type uint64Slice []uint64
-
-func NewUint64Slice() uint64Slice {
-	return make([]uint64, 0)
-}
-
-func (d uint64Slice) Len() int {
-	return len(d)
-}
-
-func (d uint64Slice) Swap(i, j int) {
-	d[i], d[j] = d[j], d[i]
-}
-
-func (d uint64Slice) Less(i, j int) bool {
-	return d[i] < d[j]
-}
+// the exit statuses
+const (
+	status_applied       = 0 // every replacement asked for was made
+	status_skipped       = 1 // some were skipped, the bytes there not being those expected
+	status_fatal_error   = 2
+	status_nothing_to_do = 3 // no replacements were asked for
+)
 
 //// GLOBAL VARIABLES ////
 
 var fromString *string = flag.String("from", "", "text to replace; used as insurance")
 var toString *string = flag.String("to", "", "replacement text")
 var quiet *bool = flag.Bool("q", false, "quiet")
+var verbose *bool = flag.Bool("v", false, "report how each file is altered on the standard error")
+var debugOutput *bool = flag.Bool("vv", false, "like -v, but also report each offset replaced or skipped and each decision made along the way")
+var inPlace *bool = flag.Bool("in-place", false, "write the replacements directly into the file rather than into a copy; no backup is kept, and an interruption can leave only some made")
+var hexOffsets *bool = flag.Bool("hex", false, "read offsets (and -range and -fill) as hexadecimal even without a 0x prefix; without it, they are decimal unless written with 0x")
+var planFile *string = flag.String("plan", "", "apply the replacements for each file listed in this plan, as written by sift -swap (\"-\" for stdin), instead of those given on the command line; an offset in the plan may give its own expected bytes and replacement, which -from and -to then only stand in for where it does not")
+var patchFile *string = flag.String("apply-patch", "", "apply the patch in this file, as written by -emit-patch (\"-\" for stdin), to the files it names or, if one is given, to that file")
+var fillByte *string = flag.String("fill", "", "instead of replacing bytes at offsets, overwrite each range given by -range with this byte (e.g., 0 or 0xff)")
+var force *bool = flag.Bool("force", false, "with -plan, alter files even if they have changed since the plan was made; with -check-in-use, alter them even if in use")
+var maxReplacements *int = flag.Int("max-replacements", 0, "refuse to alter anything if more than this many locations would be (0 for no limit)")
 var processStdin *bool = flag.Bool("stdin", false, "process stdin as one of the inputs")
 
-var fromBytes, toBytes, buffer ba.ByteArray
+var fromBytes, toBytes ba.ByteArray
+
+// -fromb as given, and the mask of the bits of fromBytes that must match
+// (nil for all of them)
+var fromPattern ba.MaskedByteArray
+var fromMask []byte
 
 //// FUNCTIONS ////
 
@@ -58,160 +59,408 @@ func main() {
 
 	var err error
 
-	flag.Var(&fromBytes, "fromb", "bytes to replace; used to make sure you don't overwrite wrong data; e.g., \"-b 00ff00AA\"")
-	flag.Var(&toBytes, "tob", "replacement bytes; e.g., \"-b 0FE32d17\"")
+	flag.Var(ba.FlagMaskedBytes{Target: &fromPattern}, "fromb", "bytes to replace; used to make sure you don't overwrite wrong data; e.g., \"-b 00ff00AA\"; a digit given as ? matches any value, e.g., \"DE??BEEF\"; \"@PATH\" gives the contents of a file")
+	flag.Var(ba.FlagBytes{Target: &toBytes}, "tob", "replacement bytes; e.g., \"-b 0FE32d17\", or \"@PATH\" for the contents of a file")
+	flag.Var(&syncMode, "fsync", "have the replacements reach the storage device before exiting: with -in-place, by syncing the file (-fsync=data syncs only its data and what is needed to read it back), and otherwise by syncing the directory once the rewritten file is renamed into place")
+	flag.Var(&fillRanges, "range", "with -fill, a range of offsets to overwrite, written START:END (END is not included); may be given more than once")
+	registerNumberFlags()
+	registerFileFlags()
 	flag.Parse() // scan the arguments list
 
-	if len(*fromString) != 0 {
-		if len(fromBytes) == 0 {
-			fromBytes = []byte(*fromString)
+	if *debugOutput {
+		myerr.SetVerbosity(myerr.LevelDebug)
+	} else if *verbose {
+		myerr.SetVerbosity(myerr.LevelInfo)
+	} else if *quiet {
+		myerr.SetVerbosity(myerr.LevelError)
+	}
+
+	if err = checkByteFlags(); err != nil {
+		myerr.MyFatal(status_fatal_error, "error: %s", err)
+		return
+	}
+
+	if !openOutputs() {
+		return
+	}
+	defer closeOutputs()
+
+	fromBytes, fromMask = fromPattern.Bytes, fromPattern.Mask
+	if *patchFile != "" {
+		if len(fromBytes) != 0 || len(*fromString) != 0 || len(toBytes) != 0 || len(*toString) != 0 || templating() || filling() || *planFile != "" {
+			myerr.MyFatal(status_fatal_error, "error: a patch gives its own bytes, so -apply-patch may not be given with -from, -fromb, -to, -tob, -template, -fill, or -plan")
+		} else if flag.NArg() > 1 {
+			myerr.MyFatal(status_fatal_error, "error: -apply-patch takes at most the one file to apply it to")
+		} else if status := applyPatchFile(*patchFile, flag.Arg(0)); status == status_fatal_error {
+			myerr.MyFatal(status_fatal_error, "error: not every replacement in the patch was made")
 		} else {
-			myerr.MyFatal(status_fatal_error, "error: specified both -from and -fromb parameters")
-			return
+			myerr.SetExitCode(status)
 		}
+		return
 	}
 
-	if len(*toString) != 0 {
-		if len(toBytes) == 0 {
-			toBytes = []byte(*toString)
-		} else {
-			myerr.MyFatal(status_fatal_error, "error: specified both -to and -tob parameters")
+	if filling() {
+		if err = fillFile(); err != nil {
+			myerr.MyFatal(status_fatal_error, "error: %s", err)
+		}
+		return
+	} else if len(fillRanges) != 0 {
+		myerr.MyFatal(status_fatal_error, "error: -range may only be given with -fill")
+		return
+	}
+
+	if len(*fromString) != 0 {
+		fromBytes = []byte(*fromString)
+	}
+
+	if templating() {
+		if len(*toString) != 0 || len(toBytes) != 0 {
+			myerr.MyFatal(status_fatal_error, "error: specified both -template and -to or -tob")
 			return
 		}
-	} else if len(toBytes) == 0 {
+	} else if len(*toString) != 0 {
+		toBytes = []byte(*toString)
+	} else if len(toBytes) == 0 && *planFile == "" {
 		myerr.MyFatal(status_fatal_error, "error: must specify either -to or -tob parameter")
 		return
 	}
 
-	if len(fromBytes) != 0 && len(fromBytes) != len(toBytes) {
-		myerr.MyFatal(status_fatal_error, "error: if you specify -from or -fromb it must be the same size as -to or -tob; %d is not equal to %d", len(fromBytes), len(toBytes))
+	if *direct && !*inPlace {
+		myerr.MyFatal(status_fatal_error, "error: -direct may only be given with -in-place")
+		return
+	}
+
+	if resizing() {
+		if fromMask != nil {
+			myerr.MyFatal(status_fatal_error, "error: -fromb may not contain ? when it differs in size from -to or -tob")
+			return
+		}
+		if *checksumRegions {
+			myerr.MyFatal(status_fatal_error, "error: -checksum-regions may not be given when -from or -fromb differs in size from -to or -tob, as the regions move")
+			return
+		}
+		if *inPlace || *planFile != "" || emitted != nil || reversed != nil {
+			myerr.MyFatal(status_fatal_error, "error: -from or -fromb may only differ in size from -to or -tob when altering a single file or with -search, without -in-place, -emit-patch, or -reverse-patch; %d is not equal to %d", len(fromBytes), len(toBytes))
+			return
+		}
+		myerr.Warn("the replacement is %d bytes where %d are replaced, so every offset after each replacement moves by %d", len(toBytes), len(fromBytes), len(toBytes)-len(fromBytes))
+	}
+
+	if *search {
+		if err = checkSearchFlags(); err != nil {
+			myerr.MyFatal(status_fatal_error, "error: %s", err)
+		} else if status := searchFiles(flag.Args()); status == status_fatal_error {
+			myerr.MyFatal(status_fatal_error, "error: not every file could be altered")
+		} else {
+			myerr.SetExitCode(status)
+		}
+		return
+	}
+
+	if *planFile != "" {
+		if flag.NArg() != 0 {
+			myerr.MyFatal(status_fatal_error, "error: specified both -plan and a file to alter")
+		} else if status := applyPlanFile(*planFile); status == status_fatal_error {
+			myerr.MyFatal(status_fatal_error, "error: not every replacement in the plan was made")
+		} else {
+			myerr.SetExitCode(status)
+		}
+		return
+	}
+
+	if flag.NArg() == 0 {
+		myerr.MyFatal(status_fatal_error, "error: must specify the file to alter")
 		return
 	}
 
-	var inFileName string
-	positions := NewUint64Slice()
+	inFileName := flag.Arg(0)
+	positions := make([]uint64, 0)
 	gotError := false
 
-	for i, arg := range flag.Args() {
-		if i == 0 {
-			inFileName = arg
+	// the offsets are read from stdin only if "-" is given among them, so
+	// that a stdin meant for something else (e.g., a loop or a cron job)
+	// is never consumed
+	readStdin := false
+	for _, arg := range flag.Args()[1:] {
+		if arg == "-" {
+			readStdin = true
+			continue
+		}
+		var v uint64
+		v, err = parseOffset(arg)
+		if err != nil {
+			myerr.MyError("error: trying to parse \"%s\" as an offset; got %s", arg, err)
+			gotError = true
 		} else {
-			var v uint64
-			v, err = strconv.ParseUint(arg, 10, 64)
-			if err != nil {
-				myerr.MyError("error: trying to parse \"%s\" as an offset; got %s", arg, err)
-				gotError = true
-			} else {
-				positions = append(positions, v)
-			}
+			positions = append(positions, v)
 		}
 	}
 
-	sort.Sort(positions)
+	if readStdin {
+		var fromStdin []uint64
+		if fromStdin, err = readOffsets(os.Stdin); err != nil {
+			myerr.MyError("error: reading offsets from stdin; %s", err)
+			gotError = true
+		}
+		positions = append(positions, fromStdin...)
+	}
 
 	if gotError {
 		myerr.MyFatal(status_fatal_error, "must exit due to errors")
 		return
 	}
 
-	inFile, oe := os.Open(inFileName)
-	if oe != nil {
-		myerr.MyFatal(status_fatal_error, "could not open file \"%s\"; %s", inFileName, oe)
+	if err = checkReplacementCount(len(positions)); err != nil {
+		myerr.MyFatal(status_fatal_error, "error: %s", err)
+		return
+	}
+	if len(positions) == 0 {
+		if !*quiet {
+			myerr.MyError("warning: no offsets given; nothing to do")
+		}
+		myerr.SetExitCode(status_nothing_to_do)
+		return
 	}
-	defer func() {
-		inFile.Close()
-	}()
 
-	outFileName, outFile, oe2 := makeTempFile(inFileName, "tmp")
-	if oe2 != nil {
-		myerr.MyFatal(status_fatal_error, "%s", oe2)
+	var applied []uint64
+	var mismatches []patch.Mismatch
+	if resizing() {
+		if err = checkNotInUse(inFileName); err == nil {
+			applied, mismatches, err = checksummed(inFileName, nil, func() ([]uint64, []patch.Mismatch, error) {
+				return applyResized(inFileName, positions)
+			})
+		}
+	} else {
+		// the counter of -template follows the offsets in order
+		sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+		fp := &patch.FilePlan{Path: inFileName}
+		for _, offset := range positions {
+			fp.Edits = append(fp.Edits, patch.FileEdit{Offset: offset})
+		}
+		var plan *patch.Plan
+		if plan, err = buildPlan(fp); err != nil {
+			myerr.MyFatal(status_fatal_error, "error: %s", err)
+			return
+		}
+		applied, mismatches, err = applyPlan(inFileName, plan)
 	}
-	complete := false
-	defer func() {
-		outFile.Close()
-		if complete {
-			var mode os.FileMode
-			if fi, e := inFile.Stat(); e == nil {
-				mode = fi.Mode()
-			} else {
-				myerr.MyPanic(e)
-			}
-			
-			var backupName string
-			var backupFile *os.File
-			backupName, backupFile, err = makeTempFile(inFileName, "backup")
-			myerr.MyPanic(err)
-			err = backupFile.Close()
-			myerr.MyPanic(err)
-			err = os.Rename(inFileName, backupName)
-			myerr.MyPanic(err)
-			err = os.Rename(outFileName, inFileName)
-			myerr.MyPanic(err)
-			err = os.Chmod(inFileName, mode)
-			myerr.MyPanic(err)
-		} else {
-			err = os.Remove(outFileName)
+	noteResult(inFileName, applied, mismatches, err)
+	if textStatus() {
+		for _, m := range mismatches {
+			fmt.Printf("warning: not same at offset %d; skipping\n", m.Offset)
 		}
-	}()
-
-	if _, err = io.Copy(outFile, inFile); err != nil {
-		myerr.MyFatal(status_fatal_error, "error: %s", err)
+	}
+	if err != nil {
+		myerr.MyFatal(status_fatal_error, "error: could not rewrite \"%s\"; %s", inFileName, err)
 		return
 	}
+	if len(mismatches) != 0 {
+		myerr.SetExitCode(status_skipped)
+	}
+}
 
-	buffer := make([]byte, len(fromBytes), len(fromBytes))
-	for _, offset := range positions {
-		skip := false
-		if len(fromBytes) != 0 {
-			_, err = outFile.ReadAt(buffer, int64(offset))
-			myerr.MyPanic(err)
-			if !sameBytes(fromBytes, buffer) {
-				fmt.Printf("warning: not same at offset %d; skipping\n", offset) 
-				skip = true
-			}
-		}
+// A file that swap writes besides those it alters, named by a flag.
+type output struct {
+	name *string
+	file **os.File
+	what string
+}
 
-		if !skip {
-			_, err = outFile.WriteAt(toBytes, int64(offset))
-			myerr.MyPanic(err)
+// Returns the files given by -report, -emit-patch, -reverse-patch, and
+// -skipped-report.
+func outputs() []output {
+	return []output{
+		{reportFile, &report, "the report"},
+		{emitFile, &emitted, "the patch"},
+		{reverseFile, &reversed, "the reverse patch"},
+		{skippedFile, &skipped, "the skipped-offset report"},
+	}
+}
+
+// Creates the files given by -report and the rest. Returns whether all
+// were created.
+func openOutputs() bool {
+	stdout := 0
+	for _, o := range outputs() {
+		if *o.name == "-" {
+			stdout++
 		}
 	}
+	if stdout > 1 {
+		myerr.MyFatal(status_fatal_error, "error: only one of -report, -emit-patch, -reverse-patch, and -skipped-report may write to stdout")
+		return false
+	}
 
-	complete = true
+	for _, o := range outputs() {
+		var err error
+		if *o.file, err = createOutput(*o.name); err != nil {
+			myerr.MyFatal(status_fatal_error, "error: could not create %s; %s", o.what, err)
+			return false
+		}
+	}
+	return true
 }
 
-// Creates (and opens) a new file using template (containing path and
-// beginning of file name) and suffix (containing a new suffix to which a
-// number is added). Returns the files name, a pointer to the open file,
-// and any error.
-func makeTempFile(template, suffix string) (fname string, file *os.File, err error) {
-	template2 := template + "." + suffix
-	for i := 0; i <= 100; i++ {
-		fname = template2 + strconv.Itoa(i)
-		file, err = os.OpenFile(fname, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
-		if err == nil {
-			break
+// Closes the files given by -report and the rest.
+func closeOutputs() {
+	for _, o := range outputs() {
+		if err := closeOutput(*o.file); err != nil {
+			myerr.MyFatal(status_fatal_error, "error: could not write %s; %s", o.what, err)
 		}
 	}
+}
+
+// Returns an error if count locations to alter is more than
+// -max-replacements allows.
+func checkReplacementCount(count int) error {
+	if *maxReplacements > 0 && count > *maxReplacements {
+		return fmt.Errorf("%d locations would be altered, more than the %d allowed by -max-replacements; nothing was altered", count, *maxReplacements)
+	}
+	return nil
+}
 
+// Fills the ranges given by -range in the file given on the command line
+// with the byte given by -fill, through applyPlan.
+func fillFile() error {
+	switch {
+	case len(fromBytes) != 0 || len(*fromString) != 0 || len(toBytes) != 0 || len(*toString) != 0 || templating():
+		return errors.New("-fill replaces whatever is in its ranges, and may not be given with -from, -fromb, -to, -tob, or -template")
+	case *planFile != "":
+		return errors.New("specified both -fill and -plan")
+	case len(fillRanges) == 0:
+		return errors.New("-fill requires at least one -range")
+	case flag.NArg() != 1:
+		return errors.New("-fill requires the file to alter, and no offsets")
+	}
+
+	if err := checkReplacementCount(len(fillRanges)); err != nil {
+		return err
+	}
+
+	fill, err := parseFill(*fillByte)
+	if err != nil {
+		return fmt.Errorf("trying to parse \"%s\" as the fill byte; got %s", *fillByte, err)
+	}
+	ranges, err := parseRanges(fillRanges)
+	if err != nil {
+		return err
+	}
+	plan, err := buildFillPlan(flag.Arg(0), fill, ranges)
 	if err != nil {
-		err = errors.New(fmt.Sprintf("could not create temp file based on \"%s\"", template))
+		return err
 	}
+	applied, mismatches, err := applyPlan(flag.Arg(0), plan)
+	noteResult(flag.Arg(0), applied, mismatches, err)
+	if err != nil {
+		return fmt.Errorf("could not rewrite \"%s\"; %s", flag.Arg(0), err)
+	}
+	return nil
+}
+
+// Applies plan to the file at path, which is rewritten with the original
+// kept as a backup or, with -in-place, written directly; with -emit-patch,
+// the file is left as it is and the changes go to the patch. With
+// -reverse-patch, the patch that undoes the changes made is written.
+// Returns the offsets written and the mismatches.
+func applyPlan(path string, plan *patch.Plan) ([]uint64, []patch.Mismatch, error) {
+	if emitted != nil {
+		return emitPlan(path, plan)
+	}
+
+	if err := checkNotInUse(path); err != nil {
+		return nil, nil, err
+	}
+	if *inPlace {
+		myerr.Info("%s: making %d replacements in place", path, len(plan.Edits))
+	} else {
+		myerr.Info("%s: making %d replacements in a copy", path, len(plan.Edits))
+	}
+
+	var originals [][]byte
+	if reversed != nil {
+		var err error
+		if originals, err = readOriginals(path, plan); err != nil {
+			return nil, nil, err
+		}
+	}
+	applied, mismatches, err := checksummed(path, plan, func() ([]uint64, []patch.Mismatch, error) {
+		if isBlockDevice(path) && !*inPlace {
+			return nil, nil, errors.New("it is a block device, which can only be altered with -in-place")
+		}
+		if *inPlace {
+			return applyPlanInPlace(path, plan)
+		}
+		return applyPlanCopy(path, plan)
+	})
+	if err == nil && reversed != nil {
+		err = emitReverse(path, plan, originals, applied)
+	}
+	return applied, mismatches, err
+}
+
+// Applies plan to a copy of the file at path, which then replaces it, with
+// the original kept as a backup.
+func applyPlanCopy(path string, plan *patch.Plan) (applied []uint64, mismatches []patch.Mismatch, err error) {
+	var backupName string
+	backupName, err = fileutil.AtomicRewriteBackup(path, func(w io.Writer, r io.Reader) error {
+		// shares or copies the data as cheaply as the system allows,
+		// keeping any holes
+		p := newProgress(path)
+		e := fileutil.CopyFileProgress(w.(*os.File), r.(*os.File), p.update)
+		p.finish()
+		if e != nil {
+			return e
+		}
 
+		applied, mismatches, e = plan.Apply(w.(patch.ReadWriterAt))
+		return e
+	})
+	if err == nil {
+		manageBackup(path, backupName)
+		err = syncRewritten(path)
+	}
 	return
 }
 
-// Returns true if the two byte slices contain the exact same bytes, false otherwise.
-func sameBytes(a, b []byte) bool {
-	if len(a) != len(b) {
-		return false
+// Applies plan by writing into the file at path itself, which avoids
+// copying what does not change. A block device is written a whole block at
+// a time.
+func applyPlanInPlace(path string, plan *patch.Plan) (applied []uint64, mismatches []patch.Mismatch, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
 	}
-	
-	for i, av := range a {
-		if av != b[i] {
-			return false
+	defer func() {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}()
+
+	var target patch.ReadWriterAt = f
+	switch {
+	case *direct:
+		var df *os.File
+		if df, err = openDirect(path); err != nil {
+			return
+		}
+		defer func() {
+			if e := df.Close(); err == nil {
+				err = e
+			}
+		}()
+		if isBlockDevice(path) {
+			target = newDirectFile(df, nil)
+		} else {
+			target = newDirectFile(df, f)
 		}
+		myerr.Debug("%s: writing around the cache in blocks of %d bytes", path, target.(*blockDevice).blockSize)
+	case isBlockDevice(path):
+		target = newBlockDevice(f)
+		myerr.Debug("%s: a block device; writing in blocks of %d bytes", path, target.(*blockDevice).blockSize)
 	}
-	
-	return true
+	if applied, mismatches, err = plan.Apply(target); err != nil {
+		return
+	}
+	err = syncFile(f)
+	return
 }
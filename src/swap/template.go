@@ -0,0 +1,110 @@
+/*
+This file implements swap's -template, a replacement that differs at each
+location: it may include the offset, a counter, or an environment value,
+e.g., to stamp unique identifiers into many places in one run.
+
+Copyright © 2012 by J. E. Ivancich.
+This work is licensed under a Creative Commons Attribution-ShareAlike 3.0 Unported License.
+See: http://creativecommons.org/licenses/by-sa/3.0/
+*/
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var replacementTemplate *string = flag.String("template", "", "replacement text in which {offset} is the offset replaced at, {counter} counts the replacements from 1, and {env:NAME} is the environment variable NAME; {offset:08x} and {counter:04d} give a format, and {{ is a brace; every replacement must be the same size as -from or -fromb")
+
+// how many replacements the template has been expanded for
+var templateCounter int
+
+// the size of the first expansion of the template, which all must share;
+// -1 until there has been one
+var templateSize = -1
+
+//// FUNCTIONS ////
+
+// Is the replacement given by -template?
+func templating() bool {
+	return *replacementTemplate != ""
+}
+
+// Returns the replacement -template gives for the next location, at
+// offset. Every expansion must be the same size as the first, and as
+// -from or -fromb where given, as a template never resizes the file.
+func templateReplacement(offset uint64) ([]byte, error) {
+	templateCounter++
+	replacement, err := expandTemplate(*replacementTemplate, offset, templateCounter)
+	if err != nil {
+		return nil, err
+	}
+	if len(fromBytes) != 0 && len(replacement) != len(fromBytes) {
+		return nil, fmt.Errorf("the template gives %d bytes at offset %d where %d are replaced; a replacement from -template must be the same size as -from or -fromb", len(replacement), offset, len(fromBytes))
+	}
+	if templateSize < 0 {
+		templateSize = len(replacement)
+	} else if len(replacement) != templateSize {
+		return nil, fmt.Errorf("the template gives %d bytes at offset %d but %d elsewhere; every replacement must be the same size", len(replacement), offset, templateSize)
+	}
+	return replacement, nil
+}
+
+// Returns tmpl with its substitutions made for the counter'th location,
+// at offset.
+func expandTemplate(tmpl string, offset uint64, counter int) ([]byte, error) {
+	var buf bytes.Buffer
+	for len(tmpl) > 0 {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			buf.WriteString(tmpl)
+			break
+		}
+		buf.WriteString(tmpl[:open])
+		tmpl = tmpl[open+1:]
+		if strings.HasPrefix(tmpl, "{") {
+			buf.WriteByte('{')
+			tmpl = tmpl[1:]
+			continue
+		}
+
+		end := strings.IndexByte(tmpl, '}')
+		if end < 0 {
+			return nil, errors.New("the template has a { with no matching }")
+		}
+		name, format := tmpl[:end], ""
+		if colon := strings.IndexByte(name, ':'); colon >= 0 {
+			name, format = name[:colon], name[colon+1:]
+		}
+		tmpl = tmpl[end+1:]
+
+		switch name {
+		case "offset":
+			buf.WriteString(formatNumber(format, offset))
+		case "counter":
+			buf.WriteString(formatNumber(format, uint64(counter)))
+		case "env":
+			value, ok := os.LookupEnv(format)
+			if !ok {
+				return nil, fmt.Errorf("the template uses the environment variable %s, which is not set", format)
+			}
+			buf.WriteString(value)
+		default:
+			return nil, fmt.Errorf("the template has an unknown substitution {%s}", name)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns n formatted by format, a printf verb with its flags and width
+// but without the % (e.g., 08x); decimal if format is empty.
+func formatNumber(format string, n uint64) string {
+	if format == "" {
+		format = "d"
+	}
+	return fmt.Sprintf("%"+format, n)
+}